@@ -0,0 +1,215 @@
+package huffman
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// statistics of data and the predicted size of its encoded file
+//
+// huffman codes are generated block by block as Writer does,
+// sizes are predicted without writing any output
+type Analysis struct {
+	Size      int64                   // bytes of data
+	Frequence [256]int64              // occurrences of each byte
+	Blocks    int                     // number of encoded blocks
+	Widths    [MaxCodeWidth + 1]int64 // number of codes of each width, summed over blocks
+	DataBits  uint64                  // encoded data in bits
+	TableSize int64                   // predicted header, block headers, huffman tables, end of stream and checksum in bytes
+	DataSize  int64                   // predicted encoded data with its bit widths in bytes
+}
+
+// Shannon entropy of data in bits per byte, 0 if data is empty
+func (analysis Analysis) Entropy() (entropy float64) {
+	if analysis.Size == 0 {
+		return 0
+	}
+	for _, count := range analysis.Frequence {
+		if count == 0 {
+			continue
+		}
+		var probability float64 = float64(count) / float64(analysis.Size)
+		entropy -= probability * math.Log2(probability)
+	}
+	return entropy
+}
+
+// average code width in bits per byte, 0 if data is empty
+func (analysis Analysis) AverageWidth() float64 {
+	if analysis.Size == 0 {
+		return 0
+	}
+	return float64(analysis.DataBits) / float64(analysis.Size)
+}
+
+// entropy / average code width, 0 if data is empty
+//
+// close to 1 for codes of a single block, may exceed 1 when blocks differ in
+// statistics, data of a single byte value has entropy 0 but needs 1 bit per byte
+func (analysis Analysis) Efficiency() float64 {
+	if analysis.DataBits == 0 {
+		return 0
+	}
+	return analysis.Entropy() / analysis.AverageWidth()
+}
+
+// predicted size of the encoded file in bytes
+func (analysis Analysis) CompressedSize() int64 {
+	return analysis.TableSize + analysis.DataSize
+}
+
+// add statistics of other, e.g. to sum up the files of a directory
+func (analysis *Analysis) Add(other Analysis) {
+	analysis.Size += other.Size
+	for char, count := range other.Frequence {
+		analysis.Frequence[char] += count
+	}
+	analysis.Blocks += other.Blocks
+	for width, count := range other.Widths {
+		analysis.Widths[width] += count
+	}
+	analysis.DataBits += other.DataBits
+	analysis.TableSize += other.TableSize
+	analysis.DataSize += other.DataSize
+}
+
+// analyze input file and predict the size Encode writes
+func Analyze(inputPath string) (analysis Analysis, err error) {
+	return AnalyzeWithOptions(inputPath, nil)
+}
+
+// same as Analyze, only BlockSize, MaxCodeWidth, Checksum, NoMetadata and AccessTime of options are used,
+// options may be nil to use default options
+func AnalyzeWithOptions(inputPath string, options *EncodeOptions) (analysis Analysis, err error) {
+	return AnalyzeContext(context.Background(), inputPath, options)
+}
+
+// same as AnalyzeWithOptions, stop when ctx is done
+func AnalyzeContext(ctx context.Context, inputPath string, options *EncodeOptions) (analysis Analysis, err error) {
+	var inputFile *os.File
+	inputFile, err = os.Open(inputPath)
+	if err != nil {
+		return analysis, fmt.Errorf("open input file %s failed:\n%w", inputPath, err)
+	}
+	defer inputFile.Close()
+	var inputInfo os.FileInfo
+	inputInfo, err = inputFile.Stat()
+	if err != nil {
+		return analysis, fmt.Errorf("stat input file %s failed:\n%w", inputPath, err)
+	}
+
+	// header with file metadata, see EncodeContext
+	var header Header = Header{Size: -1}
+	if inputInfo.Mode().IsRegular() {
+		header.Size = inputInfo.Size()
+	}
+	if options == nil || !options.NoMetadata {
+		setFileMetadata(&header, inputInfo, options != nil && options.AccessTime)
+	}
+	analysis, err = analyze(ctx, inputFile, header, options)
+	if err != nil {
+		return analysis, fmt.Errorf("analyze file %s failed:\n%w", inputPath, err)
+	}
+	return analysis, nil
+}
+
+// analyze r and predict the size EncodeStream writes, see AnalyzeWithOptions
+func AnalyzeStream(ctx context.Context, r io.Reader, options *EncodeOptions) (analysis Analysis, err error) {
+	analysis, err = analyze(ctx, r, Header{Size: -1}, options)
+	if err != nil {
+		return analysis, fmt.Errorf("analyze stream failed: %w", err)
+	}
+	return analysis, nil
+}
+
+// analyze r block by block, header is the header Writer would write
+func analyze(ctx context.Context, r io.Reader, header Header, options *EncodeOptions) (analysis Analysis, err error) {
+	err = ctx.Err()
+	if err != nil {
+		return analysis, err
+	}
+
+	// default options, see NewWriter
+	var encodeOptions EncodeOptions
+	if options != nil {
+		encodeOptions = *options
+	}
+	if encodeOptions.BlockSize <= 0 {
+		encodeOptions.BlockSize = DefaultBlockSize
+	}
+	if encodeOptions.BlockSize > MaxBlockSize {
+		encodeOptions.BlockSize = MaxBlockSize
+	}
+	if encodeOptions.MaxCodeWidth == 0 {
+		encodeOptions.MaxCodeWidth = DefaultMaxCodeWidth
+	}
+	if encodeOptions.Checksum == ChecksumDefault {
+		encodeOptions.Checksum = DefaultChecksum
+	}
+
+	// header, end of stream and checksum
+	header.Checksum = encodeOptions.Checksum
+	var headerSize int
+	headerSize, err = writeHeader(io.Discard, header)
+	if err != nil {
+		return analysis, err
+	}
+	analysis.TableSize = int64(headerSize) + blockHeaderSize
+	if hash := newChecksumHash(encodeOptions.Checksum); hash != nil {
+		analysis.TableSize += int64(hash.Size())
+	}
+
+	// blocks
+	var input *progressReader = newProgressReader(ctx, r, nil, ProgressEvent{})
+	var block bytes.Buffer
+	for {
+		block.Reset()
+		_, err = block.ReadFrom(io.LimitReader(input, int64(encodeOptions.BlockSize)))
+		if err != nil {
+			return analysis, err
+		}
+		if block.Len() == 0 {
+			break
+		}
+		err = analysis.addBlock(block.Bytes(), encodeOptions.MaxCodeWidth)
+		if err != nil {
+			return analysis, err
+		}
+		if block.Len() < encodeOptions.BlockSize {
+			break
+		}
+	}
+	return analysis, ctx.Err()
+}
+
+// add statistics and predicted sizes of a block, see Writer.writeBlock
+func (analysis *Analysis) addBlock(block []byte, maxWidth int) (err error) {
+	var frequence map[byte]int = getFrequence(string(block))
+	var codes HuffmanCodes
+	codes, err = frequenceToCodes(frequence, maxWidth)
+	if err != nil {
+		return fmt.Errorf("generate huffman codes failed: %w", err)
+	}
+	var tableSize int
+	tableSize, err = writeHuffmanTable(io.Discard, codes)
+	if err != nil {
+		return err
+	}
+
+	var bits uint64 = 0
+	for char, count := range frequence {
+		analysis.Frequence[char] += int64(count)
+		analysis.Widths[codes[char].Width]++
+		bits += uint64(count) * uint64(codes[char].Width)
+	}
+	analysis.Size += int64(len(block))
+	analysis.Blocks++
+	analysis.DataBits += bits
+	analysis.TableSize += blockHeaderSize + int64(tableSize)
+	analysis.DataSize += 8 + int64((bits+7)/8)
+	return nil
+}
@@ -0,0 +1,88 @@
+package huffman
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// predicted sizes are the sizes encoding writes
+func TestAnalyzePrediction(t *testing.T) {
+	var texts = map[string][]byte{
+		"empty":       nil,
+		"single byte": {'a'},
+		"one value":   bytes.Repeat([]byte{'a'}, 5000),
+		"text":        benchmarkText(5000),
+		"all bytes":   bytes.Repeat(benchmarkText(256), 30),
+	}
+	for i := range texts["all bytes"] {
+		texts["all bytes"][i] += byte(i)
+	}
+	var options = []EncodeOptions{
+		{},
+		{BlockSize: 1000},
+		{BlockSize: 777, MaxCodeWidth: 9, Checksum: ChecksumSHA256},
+		{MaxCodeWidth: 8, Checksum: ChecksumNone},
+		{BlockSize: 4096, Checksum: ChecksumXXH64, NoMetadata: true},
+	}
+	var dir string = t.TempDir()
+	var ctx context.Context = context.Background()
+	for textName, text := range texts {
+		// default options match EncodeBytes
+		analysis, err := AnalyzeStream(ctx, bytes.NewReader(text), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", textName, err)
+		}
+		encoded, _, err := EncodeBytes(text)
+		if err != nil {
+			t.Fatalf("%s: %v", textName, err)
+		}
+		if analysis.CompressedSize() != int64(len(encoded)) {
+			t.Errorf("%s: predicted %d bytes, EncodeBytes wrote %d", textName, analysis.CompressedSize(), len(encoded))
+		}
+
+		var input string = filepath.Join(dir, "input")
+		if err = os.WriteFile(input, text, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for i := range options {
+			var name string = fmt.Sprintf("%s, options %+v", textName, options[i])
+
+			// stream
+			var buffer bytes.Buffer
+			encodeSize, _, err := EncodeStream(ctx, bytes.NewReader(text), &buffer, &options[i])
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			analysis, err = AnalyzeStream(ctx, bytes.NewReader(text), &options[i])
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if analysis.TableSize != int64(encodeSize.HuffmanTable) || analysis.DataSize != int64(encodeSize.EncodedData) || analysis.CompressedSize() != int64(buffer.Len()) {
+				t.Errorf("%s: stream predicted %d + %d bytes, wrote %d + %d", name, analysis.TableSize, analysis.DataSize, encodeSize.HuffmanTable, encodeSize.EncodedData)
+			}
+
+			// file with metadata
+			var output string = filepath.Join(dir, "output")
+			var fileOptions EncodeOptions = options[i]
+			fileOptions.Force = true
+			if _, _, err = EncodeWithOptions(input, output, &fileOptions); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			info, err := os.Stat(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			analysis, err = AnalyzeWithOptions(input, &options[i])
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if analysis.CompressedSize() != info.Size() {
+				t.Errorf("%s: file predicted %d bytes, wrote %d", name, analysis.CompressedSize(), info.Size())
+			}
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux || openbsd || dragonfly || solaris || illumos
+
+package huffman
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// access time of file, zero if unknown
+func accessTime(info os.FileInfo) time.Time {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(stat.Atim.Unix())
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package huffman
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// access time of file, zero if unknown
+func accessTime(info os.FileInfo) time.Time {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(0, data.LastAccessTime.Nanoseconds())
+}
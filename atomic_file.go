@@ -0,0 +1,93 @@
+package huffman
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// number of attempts to find an unused temporary file name
+const atomicFileAttempts = 100
+
+// file replacing its destination only when committed
+//
+// data is written to a hidden temporary file next to the destination, Commit
+// syncs it and renames it to the destination, so a failed write never leaves a
+// partial file under the destination name or overwrites a previous copy
+type AtomicFile struct {
+	*os.File
+	path      string // destination path
+	committed bool
+	closed    bool
+}
+
+// create a temporary file for path in the same directory
+//
+// the directory of path must exist
+func CreateAtomicFile(path string) (ret *AtomicFile, err error) {
+	var dir string = filepath.Dir(path)
+	var base string = filepath.Base(path)
+	for i := 0; i < atomicFileAttempts; i++ {
+		var name string = filepath.Join(dir, "."+base+"."+strconv.FormatUint(rand.Uint64(), 36)+".tmp")
+		var file *os.File
+		file, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = &AtomicFile{File: file, path: path}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("create temporary file for %s failed: %w", path, err)
+}
+
+// destination path of the file
+func (file *AtomicFile) Path() string {
+	return file.path
+}
+
+// sync and close the temporary file and rename it to the destination
+//
+// the temporary file is removed if any step fails
+func (file *AtomicFile) Commit() (err error) {
+	if file.closed {
+		return fmt.Errorf("commit closed file %s", file.path)
+	}
+	file.closed = true
+
+	err = file.File.Sync()
+	if err != nil {
+		file.File.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("sync %s failed: %w", file.Name(), err)
+	}
+	err = file.File.Close()
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("close %s failed: %w", file.Name(), err)
+	}
+	err = os.Rename(file.Name(), file.path)
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("rename %s to %s failed: %w", file.Name(), file.path, err)
+	}
+	file.committed = true
+	return nil
+}
+
+// close and remove the temporary file if not committed
+//
+// safe to call after Commit or more than once, typically deferred
+func (file *AtomicFile) Close() error {
+	if file.closed {
+		return nil
+	}
+	file.closed = true
+	file.File.Close()
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,131 @@
+package huffman
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// names of files in dir
+func dirNames(t *testing.T, dir string) (names []string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// destination is only written by Commit, Close removes the temporary file
+func TestAtomicFile(t *testing.T) {
+	var dir string = t.TempDir()
+	var path string = filepath.Join(dir, "output")
+	writeTestFiles(t, dir, map[string]string{"output": "previous"})
+
+	file, err := CreateAtomicFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("discarded"))
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "previous" {
+		t.Fatalf("destination changed to %q", data)
+	}
+	if names := dirNames(t, dir); len(names) != 1 {
+		t.Fatalf("files left: %v", names)
+	}
+
+	file, err = CreateAtomicFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	file.Write([]byte("committed"))
+	if err = file.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "committed" {
+		t.Fatalf("destination is %q", data)
+	}
+	if names := dirNames(t, dir); len(names) != 1 {
+		t.Fatalf("files left: %v", names)
+	}
+}
+
+// encoding or decoding failing after output is partly written keeps the previous output
+func TestFailedOutputUnchanged(t *testing.T) {
+	var dir string = t.TempDir()
+	var text []byte = benchmarkText(64 << 10)
+	var input string = filepath.Join(dir, "input.txt")
+	var encoded string = filepath.Join(dir, "input.bin")
+	var corrupt string = filepath.Join(dir, "corrupt.bin")
+	var output string = filepath.Join(dir, "output")
+	if err := os.WriteFile(input, text, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := EncodeWithOptions(input, encoded, &EncodeOptions{BlockSize: 16 << 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	// corrupt the table of the last block, earlier blocks are decoded and written
+	data, err := os.ReadFile(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ReadInfo(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last BlockInfo = info.Blocks[len(info.Blocks)-1]
+	data[last.Offset+blockHeaderSize] = tableBitmap + 1
+	if err = os.WriteFile(corrupt, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var previous []byte = []byte("previous output")
+	var tests = map[string]func() error{
+		"corrupt input": func() error {
+			_, _, err := DecodeWithOptions(corrupt, output, &DecodeOptions{Force: true})
+			if !errors.Is(err, ErrCorruptTable) {
+				t.Errorf("decode got %v, want ErrCorruptTable", err)
+			}
+			return err
+		},
+		"canceled encoding": func() error {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var options EncodeOptions = EncodeOptions{Force: true, BlockSize: 1 << 10}
+			options.Progress = func(event ProgressEvent) {
+				if event.Type == ProgressBytes {
+					cancel()
+				}
+			}
+			_, _, err := EncodeContext(ctx, input, output, &options)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("encode got %v, want context.Canceled", err)
+			}
+			return err
+		},
+	}
+	for name, run := range tests {
+		if err = os.WriteFile(output, previous, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if run() == nil {
+			t.Fatalf("%s: succeeded", name)
+		}
+		if data, _ := os.ReadFile(output); !bytes.Equal(data, previous) {
+			t.Errorf("%s: previous output changed", name)
+		}
+		if names := dirNames(t, dir); len(names) != 4 {
+			t.Errorf("%s: files left: %v", name, names)
+		}
+	}
+}
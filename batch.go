@@ -0,0 +1,176 @@
+package huffman
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memory budget shared by batch workers
+//
+// a file larger than the whole budget is started when no other file is running
+type memoryBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+// create a new memory budget, limit 0 for no limit
+func newMemoryBudget(limit int64) (ret *memoryBudget) {
+	ret = new(memoryBudget)
+	ret.cond = sync.NewCond(&ret.mu)
+	ret.limit = limit
+	return ret
+}
+
+// wait until size bytes fit in the budget and take them
+func (budget *memoryBudget) acquire(size int64) {
+	if budget.limit <= 0 {
+		return
+	}
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	for budget.used > 0 && budget.used+size > budget.limit {
+		budget.cond.Wait()
+	}
+	budget.used += size
+}
+
+// give back size bytes taken by acquire
+func (budget *memoryBudget) release(size int64) {
+	if budget.limit <= 0 {
+		return
+	}
+	budget.mu.Lock()
+	budget.used -= size
+	budget.mu.Unlock()
+	budget.cond.Broadcast()
+}
+
+// call process for each index in [0, count) with at most jobs goroutines
+//
+// jobs 0 for GOMAXPROCS, files are started in index order, each one after
+// weight(index) bytes of memory are available, memory 0 for no limit
+//
+// when ctx is done, remaining indexes are passed to process without waiting
+// for memory, process is expected to return at once
+func runBatch(ctx context.Context, count int, jobs int, memory int64, weight func(index int) int64, process func(index int)) {
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	jobs = min(jobs, count)
+	var budget *memoryBudget = newMemoryBudget(memory)
+
+	var indexes chan int = make(chan int)
+	var weights []int64 = make([]int64, count)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				process(index)
+				budget.release(weights[index])
+			}
+		}()
+	}
+
+	for index := 0; index < count; index++ {
+		if memory > 0 && ctx.Err() == nil {
+			weights[index] = weight(index)
+		}
+		budget.acquire(weights[index])
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// estimated memory of encoding or decoding a file
+//
+// data is processed block by block, a block is held both encoded and decoded
+func fileMemory(size int64, blockSize int) int64 {
+	return 2 * min(size, int64(blockSize))
+}
+
+// estimated memory of encoding a file, file size if it can not be read
+func encodeMemory(path string, options *EncodeOptions) int64 {
+	var blockSize int = DefaultBlockSize
+	if options != nil && options.BlockSize > 0 {
+		blockSize = min(options.BlockSize, MaxBlockSize)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fileMemory(info.Size(), blockSize)
+}
+
+// estimated memory of decoding a file, original size from header and block
+// size from the first block header if known
+//
+// blocks after the first are never larger, legacy files are a single block
+func decodeMemory(path string) int64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0
+	}
+	var size int64 = info.Size()
+	var reader *bufio.Reader = bufio.NewReader(file)
+	header, _, err := readHeader(reader)
+	if err == nil && header.Size > size {
+		size = header.Size
+	}
+	var blockSize int64 = size
+	if err == nil && header.Version != LegacyVersion {
+		var blockHeader [blockHeaderSize]byte
+		_, err = io.ReadFull(reader, blockHeader[:])
+		if err == nil {
+			// decoded size is unknown without size in header, but never below the first block
+			blockSize = int64(binary.BigEndian.Uint32(blockHeader[:]))
+			size = max(size, blockSize)
+		}
+	}
+	return fileMemory(size, int(min(blockSize, MaxBlockSize)))
+}
+
+// report errors found before processing as finished files
+func reportBatchErrors(progress ProgressFunc, errors []BatchError) {
+	for _, batchErr := range errors {
+		progress.report(ProgressEvent{Type: ProgressFileFinished, Path: batchErr.Path, Total: -1, Err: batchErr.Err})
+	}
+}
+
+// sort errors in the order files are walked, errors of the same path keep their order
+//
+// errors found while collecting files, choosing output paths and processing
+// files are reported together in a stable order, see GetFilesInDir
+func sortBatchErrors(errors []BatchError) {
+	sort.SliceStable(errors, func(i, j int) bool {
+		return walkLess(errors[i].Path, errors[j].Path)
+	})
+}
+
+// path1 is walked before path2, paths are compared element by element
+func walkLess(path1, path2 string) bool {
+	var elements1, elements2 []string = strings.Split(filepath.ToSlash(path1), "/"), strings.Split(filepath.ToSlash(path2), "/")
+	for i := 0; i < len(elements1) && i < len(elements2); i++ {
+		if elements1[i] != elements2[i] {
+			return elements1[i] < elements2[i]
+		}
+	}
+	return len(elements1) < len(elements2)
+}
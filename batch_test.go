@@ -0,0 +1,31 @@
+package huffman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// memory of decoding a file follows the block size it was encoded with
+func TestDecodeMemory(t *testing.T) {
+	var dir string = t.TempDir()
+	var text []byte = benchmarkText(1000)
+	var tests = []struct {
+		blockSize int
+		want      int64
+	}{
+		{0, 2000},
+		{100, 200},
+		{1000, 2000},
+		{4000, 2000},
+	}
+	for _, test := range tests {
+		var path string = filepath.Join(dir, "encoded.bin")
+		if err := os.WriteFile(path, encodeText(t, text, &EncodeOptions{BlockSize: test.blockSize}), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if memory := decodeMemory(path); memory != test.want {
+			t.Errorf("block size %d: memory %d, want %d", test.blockSize, memory, test.want)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package huffman
 
 type BitsReader struct {
 	data           []byte
@@ -7,11 +7,10 @@ type BitsReader struct {
 }
 
 // create a new bits reader
+//
+// nil data is read as empty data, return nil if width is out of range
 func NewBitsReader(data []byte, width int) (ret *BitsReader) {
-	if data == nil {
-		return nil
-	}
-	if width > len(data)*8 {
+	if width < 0 || width > len(data)*8 {
 		return nil
 	}
 
@@ -24,7 +23,7 @@ func NewBitsReader(data []byte, width int) (ret *BitsReader) {
 
 // create a new bits reader from a single byte
 func NewBitsReaderFromByte(data byte, width int) (ret *BitsReader) {
-	if width > 8 {
+	if width < 0 || width > 8 {
 		return nil
 	}
 
@@ -58,13 +57,16 @@ func NewBitsReaderFromUint64(data uint64, width int) (ret *BitsReader) {
 	return NewBitsReader(recorder.Result(), recorder.Width())
 }
 
-// seek to the given offset
+// seek to the given offset relative to current position
+//
+// stop at the start or the end if offset is out of range
 func (reader *BitsReader) Seek(offset int) {
-	reader.currentPointer += offset
-	if reader.currentPointer < 0 {
+	if offset < -reader.currentPointer {
 		reader.currentPointer = 0
-	} else if reader.currentPointer > reader.width {
+	} else if offset > reader.width-reader.currentPointer {
 		reader.currentPointer = reader.width
+	} else {
+		reader.currentPointer += offset
 	}
 }
 
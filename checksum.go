@@ -0,0 +1,89 @@
+package huffman
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+)
+
+// checksum algorithm of original data
+type ChecksumAlgorithm int
+
+const (
+	ChecksumDefault ChecksumAlgorithm = iota // DefaultChecksum when writing
+	ChecksumNone
+	ChecksumCRC32
+	ChecksumXXH64
+	ChecksumSHA256
+)
+
+// checksum algorithm used by Writer if not specified
+const DefaultChecksum = ChecksumCRC32
+
+// names of checksum algorithms
+var checksumNames = map[ChecksumAlgorithm]string{
+	ChecksumNone:   "none",
+	ChecksumCRC32:  "crc32",
+	ChecksumXXH64:  "xxh64",
+	ChecksumSHA256: "sha256",
+}
+
+func (algorithm ChecksumAlgorithm) String() string {
+	if algorithm == ChecksumDefault {
+		return DefaultChecksum.String()
+	}
+	if name, ok := checksumNames[algorithm]; ok {
+		return name
+	}
+	return fmt.Sprintf("checksum(%d)", int(algorithm))
+}
+
+// parse checksum algorithm from name, case insensitive
+func ParseChecksumAlgorithm(name string) (algorithm ChecksumAlgorithm, err error) {
+	for algorithm, algorithmName := range checksumNames {
+		if strings.EqualFold(name, algorithmName) {
+			return algorithm, nil
+		}
+	}
+	return ChecksumDefault, fmt.Errorf("unknown checksum algorithm %s", name)
+}
+
+// checksum of original data
+type Checksum struct {
+	Algorithm ChecksumAlgorithm
+	Value     []byte // big endian, nil if Algorithm is ChecksumNone
+}
+
+// format as algorithm:hex value
+func (checksum Checksum) String() string {
+	if checksum.Algorithm == ChecksumNone {
+		return checksum.Algorithm.String()
+	}
+	return checksum.Algorithm.String() + ":" + hex.EncodeToString(checksum.Value)
+}
+
+// create hash of the algorithm, nil for ChecksumNone
+func newChecksumHash(algorithm ChecksumAlgorithm) hash.Hash {
+	switch algorithm {
+	case ChecksumCRC32:
+		return crc32.NewIEEE()
+	case ChecksumXXH64:
+		return newXXHash64()
+	case ChecksumSHA256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// id of checksum algorithm stored in header flags
+func checksumID(algorithm ChecksumAlgorithm) byte {
+	return byte(algorithm - ChecksumNone)
+}
+
+// checksum algorithm of id stored in header flags
+func checksumFromID(id byte) ChecksumAlgorithm {
+	return ChecksumAlgorithm(id) + ChecksumNone
+}
@@ -0,0 +1,84 @@
+package huffman
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// known answers of xxh64 with seed 0
+func TestXXHash64(t *testing.T) {
+	var tests = map[string]string{
+		"":    "ef46db3751d8e999",
+		"abc": "44bc2cf5ad770999",
+	}
+	for input, want := range tests {
+		var hash *xxhash64 = newXXHash64()
+		hash.Write([]byte(input))
+		if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+			t.Errorf("xxh64(%q) = %s, want %s", input, got, want)
+		}
+	}
+
+	// writes split at any point give the same sum, across the 32 byte stripes
+	var text []byte = benchmarkText(100)
+	var whole *xxhash64 = newXXHash64()
+	whole.Write(text)
+	for split := 0; split <= len(text); split++ {
+		var hash *xxhash64 = newXXHash64()
+		hash.Write(text[:split])
+		hash.Write(text[split:])
+		if hash.Sum64() != whole.Sum64() {
+			t.Fatalf("split at %d: sum %x, want %x", split, hash.Sum64(), whole.Sum64())
+		}
+	}
+}
+
+// stored checksum differing from decoded data is rejected
+func TestChecksumMismatch(t *testing.T) {
+	// 4 characters of equal frequence have codes of 2 bits, any flipped bit
+	// in encoded data still decodes to the same number of characters
+	var text []byte = bytes.Repeat([]byte("abcd"), 16)
+	var dir string = t.TempDir()
+	for _, algorithm := range []ChecksumAlgorithm{ChecksumCRC32, ChecksumXXH64, ChecksumSHA256} {
+		var buffer bytes.Buffer
+		var writer *Writer = NewWriter(&buffer, &EncodeOptions{Checksum: algorithm})
+		writer.Write(text)
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		var data []byte = buffer.Bytes()
+		if _, err := DecodeBytes(data); err != nil {
+			t.Fatalf("%v: decode failed: %v", algorithm, err)
+		}
+
+		// flip a bit of the stored checksum at the end of file,
+		// or of the 16 bytes of encoded data before end of stream
+		var checksumSize int = newChecksumHash(algorithm).Size()
+		for _, offset := range []int{len(data) - 1, len(data) - checksumSize - blockHeaderSize - 16} {
+			var corrupt []byte = bytes.Clone(data)
+			corrupt[offset] ^= 0x80
+			_, err := DecodeBytes(corrupt)
+			var formatErr *FormatError
+			if !errors.Is(err, ErrChecksumMismatch) || !errors.As(err, &formatErr) {
+				t.Errorf("%v: bit flipped at %d: got %v, want *FormatError with ErrChecksumMismatch", algorithm, offset, err)
+			}
+
+			// no output file is left
+			var input string = filepath.Join(dir, "corrupt.bin")
+			if err = os.WriteFile(input, corrupt, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = Decode(input, filepath.Join(dir, "corrupt.txt"))
+			if !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("%v: bit flipped at %d: decode file got %v, want ErrChecksumMismatch", algorithm, offset, err)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("%v: bit flipped at %d: %d files left in output directory", algorithm, offset, len(entries)-1)
+			}
+		}
+	}
+}
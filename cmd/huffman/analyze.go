@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Anslen/huffman"
+)
+
+// options of analyze command
+type analyzeFlags struct {
+	summary   bool
+	json      bool
+	top       int
+	maxWidth  int
+	blockSize int64
+	checksum  huffman.ChecksumAlgorithm
+	noName    bool
+	atime     bool
+}
+
+// register options of analyze command
+func (flags *analyzeFlags) optionSet() (set *optionSet) {
+	set = newOptionSet("analyze", "[options] <file|directory>...", "Report entropy, code widths and the predicted compressed size of files without writing output,\n"+
+		"directories are analyzed recursively, - for stdin. With several files the total is reported as well.")
+	set.boolOption(&flags.summary, "s", "summary", "only report the total of all files")
+	set.boolOption(&flags.json, "", "json", "print one JSON object per file and line, then the total for several files")
+	set.intOption(&flags.top, "t", "top", "n", "number of most frequent symbols reported (default 10)", 0, 256)
+	set.intOption(&flags.maxWidth, "l", "max-width", "bits", fmt.Sprintf("max huffman code width in bits (default %d)", huffman.DefaultMaxCodeWidth), 1, huffman.MaxCodeWidth)
+	set.boolOption(&flags.noName, "n", "no-name", "predict size without name, mode and times of input, see 'huffman help compress'")
+	set.boolOption(&flags.atime, "", "atime", "predict size with access time of input stored, see 'huffman help compress'")
+	set.sizeOption(&flags.blockSize, "", "block-size", "size of blocks coded with their own huffman table, e.g. 64K (default 1M)")
+	set.funcOption("", "checksum", "algorithm", "checksum algorithm: crc32 (default), xxh64, sha256 or none", func(str string) (err error) {
+		flags.checksum, err = huffman.ParseChecksumAlgorithm(str)
+		return err
+	})
+	return set
+}
+
+// option set of analyze command for help
+func analyzeOptions() *optionSet {
+	return new(analyzeFlags).optionSet()
+}
+
+// analysis of a file or the total as JSON
+type jsonAnalysis struct {
+	Type           string       `json:"type"` // "result", "file" or "summary"
+	Path           string       `json:"path,omitempty"`
+	Files          *int         `json:"files,omitempty"` // summary only
+	Size           int64        `json:"size"`
+	Entropy        float64      `json:"entropy"`
+	AverageWidth   float64      `json:"average_width"`
+	Efficiency     float64      `json:"efficiency"`
+	Blocks         int          `json:"blocks"`
+	TableSize      int64        `json:"table_size"`
+	DataSize       int64        `json:"data_size"`
+	CompressedSize int64        `json:"compressed_size"`
+	Ratio          float64      `json:"ratio"`
+	TopSymbols     []jsonSymbol `json:"top_symbols,omitempty"`
+	Widths         []jsonWidth  `json:"widths,omitempty"`
+	Error          *jsonError   `json:"error,omitempty"`
+}
+
+// frequency of a symbol as JSON
+type jsonSymbol struct {
+	Symbol byte    `json:"symbol"`
+	Char   string  `json:"char"`
+	Count  int64   `json:"count"`
+	Share  float64 `json:"share"`
+}
+
+// number of codes of a width as JSON
+type jsonWidth struct {
+	Width int   `json:"width"`
+	Codes int64 `json:"codes"`
+}
+
+// analyze [options] <file|directory>...
+func runAnalyze(ctx context.Context, args []string) int {
+	var flags analyzeFlags = analyzeFlags{top: 10}
+	var set *optionSet = flags.optionSet()
+	positional, code, ok := parseOptions(set, args)
+	if !ok {
+		return code
+	}
+	if len(positional) == 0 && stdinPiped() {
+		positional = []string{"-"}
+	}
+	if len(positional) == 0 {
+		return usageError(set, fmt.Errorf("input file required"))
+	}
+
+	var options huffman.EncodeOptions
+	options.BlockSize = int(min(flags.blockSize, huffman.MaxBlockSize))
+	options.MaxCodeWidth = flags.maxWidth
+	options.Checksum = flags.checksum
+	options.NoMetadata = flags.noName
+	options.AccessTime = flags.atime
+
+	// stdin is analyzed as is, other arguments are collected recursively
+	var paths []string
+	var errorCount int = 0
+	for _, arg := range positional {
+		if arg == "-" {
+			paths = append(paths, arg)
+			continue
+		}
+		files, count := collectFiles([]string{arg})
+		paths = append(paths, files...)
+		errorCount += count
+	}
+
+	var total huffman.Analysis
+	var successCount int = 0
+	var several bool = len(paths) > 1 || errorCount > 0
+	for index, path := range paths {
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "Error: analyze canceled: %v\n", ctx.Err())
+			return exitFailure
+		}
+		var analysis huffman.Analysis
+		var err error
+		if path == "-" {
+			analysis, err = huffman.AnalyzeStream(ctx, os.Stdin, &options)
+		} else {
+			path = filepath.Clean(path)
+			analysis, err = huffman.AnalyzeContext(ctx, path, &options)
+		}
+		if err != nil {
+			errorCount++
+		} else {
+			successCount++
+			total.Add(analysis)
+		}
+
+		if flags.json {
+			if !flags.summary || err != nil {
+				var result jsonAnalysis = analysisJSON(analysis, err, flags.top)
+				result.Type, result.Path = "result", path
+				if several {
+					result.Type = "file"
+				}
+				writeJSON(os.Stdout, result)
+			}
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: analyze %s failed: %v\n", path, err)
+			continue
+		}
+		if flags.summary {
+			continue
+		}
+		if index > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("File: %s\n", path)
+		printAnalysis(os.Stdout, analysis, flags.top)
+	}
+
+	// total of several files
+	if several || (flags.summary && len(paths) > 0) {
+		if flags.json {
+			var result jsonAnalysis = analysisJSON(total, nil, flags.top)
+			result.Type, result.Files = "summary", &successCount
+			writeJSON(os.Stdout, result)
+		} else {
+			if !flags.summary && successCount > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("Total: %d files\n", successCount)
+			printAnalysis(os.Stdout, total, flags.top)
+		}
+	}
+	return batchExitCode(successCount, errorCount)
+}
+
+// print analysis as human readable text, with top most frequent symbols
+func printAnalysis(w io.Writer, analysis huffman.Analysis, top int) {
+	fmt.Fprintf(w, "Size: %d bytes\n", analysis.Size)
+	fmt.Fprintf(w, "Entropy: %.4f bits per byte\n", analysis.Entropy())
+	fmt.Fprintf(w, "Average code width: %.4f bits per byte\n", analysis.AverageWidth())
+	fmt.Fprintf(w, "Coding efficiency: %.2f%%\n", analysis.Efficiency()*100)
+	fmt.Fprintf(w, "Blocks: %d\n", analysis.Blocks)
+	fmt.Fprintf(w, "Predicted huffman tables and headers: %d bytes\n", analysis.TableSize)
+	fmt.Fprintf(w, "Predicted encoded data: %d bytes\n", analysis.DataSize)
+	fmt.Fprintf(w, "Predicted compressed size: %d bytes\n", analysis.CompressedSize())
+	if analysis.Size > 0 {
+		fmt.Fprintf(w, "Predicted compression ratio: %.2f%%\n", percent(analysis.CompressedSize(), analysis.Size))
+	}
+
+	// top symbols
+	var symbols []byte = topSymbols(analysis, top)
+	if len(symbols) > 0 {
+		fmt.Fprintf(w, "Top symbols:\n")
+		fmt.Fprintf(w, "  %-6s %12s %8s\n", "symbol", "count", "share")
+		for _, char := range symbols {
+			var count int64 = analysis.Frequence[char]
+			fmt.Fprintf(w, "  %-6s %12d %7.2f%%\n", symbolName(char), count, percent(count, analysis.Size))
+		}
+	}
+
+	// histogram of code widths, bars scaled to the most frequent width
+	var widest int64 = 0
+	for _, count := range analysis.Widths {
+		widest = max(widest, count)
+	}
+	if widest == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Code widths:\n")
+	fmt.Fprintf(w, "  %5s %8s\n", "width", "codes")
+	for width, count := range analysis.Widths {
+		if count == 0 {
+			continue
+		}
+		var bar string = strings.Repeat("#", int(max(1, count*40/widest)))
+		fmt.Fprintf(w, "  %5d %8d  %s\n", width, count, bar)
+	}
+}
+
+// analysis as JSON, Type and Path are left to the caller
+func analysisJSON(analysis huffman.Analysis, err error, top int) (ret jsonAnalysis) {
+	ret.Error = newJSONError(err)
+	if err != nil {
+		return ret
+	}
+	ret.Size = analysis.Size
+	ret.Entropy = analysis.Entropy()
+	ret.AverageWidth = analysis.AverageWidth()
+	ret.Efficiency = analysis.Efficiency()
+	ret.Blocks = analysis.Blocks
+	ret.TableSize = analysis.TableSize
+	ret.DataSize = analysis.DataSize
+	ret.CompressedSize = analysis.CompressedSize()
+	ret.Ratio = ratio(ret.CompressedSize, ret.Size)
+	for _, char := range topSymbols(analysis, top) {
+		var count int64 = analysis.Frequence[char]
+		ret.TopSymbols = append(ret.TopSymbols, jsonSymbol{Symbol: char, Char: symbolName(char), Count: count, Share: ratio(count, analysis.Size)})
+	}
+	for width, count := range analysis.Widths {
+		if count > 0 {
+			ret.Widths = append(ret.Widths, jsonWidth{Width: width, Codes: count})
+		}
+	}
+	return ret
+}
+
+// at most top symbols that occur, most frequent first, then by symbol
+func topSymbols(analysis huffman.Analysis, top int) (symbols []byte) {
+	for char, count := range analysis.Frequence {
+		if count > 0 {
+			symbols = append(symbols, byte(char))
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if analysis.Frequence[symbols[i]] != analysis.Frequence[symbols[j]] {
+			return analysis.Frequence[symbols[i]] > analysis.Frequence[symbols[j]]
+		}
+		return symbols[i] < symbols[j]
+	})
+	return symbols[:min(top, len(symbols))]
+}
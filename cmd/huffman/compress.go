@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Anslen/huffman"
+)
+
+// options of compress command
+type compressFlags struct {
+	fileOptions
+	maxWidth  int
+	blockSize int64
+	checksum  huffman.ChecksumAlgorithm
+	verify    bool
+	noName    bool
+	atime     bool
+}
+
+// register options of compress command
+func (flags *compressFlags) optionSet() (set *optionSet) {
+	set = newOptionSet("compress", "[options] <input>", "Compress a file, or all files in a directory into an output directory.")
+	flags.register(set)
+	set.boolOption(&flags.verify, "", "verify", "decode each output and compare it with input before saving it (implied by --rm)")
+	set.boolOption(&flags.noName, "n", "no-name", "do not store name, mode and times of input, output then only depends on its content")
+	set.boolOption(&flags.atime, "", "atime", "also store access time of input, output then changes whenever input is read")
+	set.intOption(&flags.maxWidth, "l", "max-width", "bits", fmt.Sprintf("max huffman code width in bits (default %d)", huffman.DefaultMaxCodeWidth), 1, huffman.MaxCodeWidth)
+	set.sizeOption(&flags.blockSize, "", "block-size", "size of blocks coded with their own huffman table, e.g. 64K (default 1M)")
+	set.funcOption("", "checksum", "algorithm", "checksum algorithm: crc32 (default), xxh64, sha256 or none", func(str string) (err error) {
+		flags.checksum, err = huffman.ParseChecksumAlgorithm(str)
+		return err
+	})
+	return set
+}
+
+// option set of compress command for help
+func compressOptions() *optionSet {
+	return new(compressFlags).optionSet()
+}
+
+// compress [options] <input>
+func runCompress(ctx context.Context, args []string) int {
+	var flags compressFlags
+	var set *optionSet = flags.optionSet()
+	positional, code, ok := parseOptions(set, args)
+	if !ok {
+		return code
+	}
+	err := flags.resolve(positional, "out.bin")
+	if err != nil {
+		return usageError(set, err)
+	}
+
+	var options huffman.EncodeOptions
+	options.BlockSize = int(min(flags.blockSize, huffman.MaxBlockSize))
+	options.MaxCodeWidth = flags.maxWidth
+	options.Checksum = flags.checksum
+	options.Force = flags.force
+	options.Verify = flags.verify
+	options.RemoveInput = flags.remove && !flags.keep // keep wins over remove
+	options.NoMetadata = flags.noName
+	options.AccessTime = flags.atime
+	options.Jobs = flags.jobs
+	options.BatchMemory = flags.batchMemory
+
+	if flags.batch {
+		return compressBatch(ctx, &flags, &options)
+	}
+	return compressFile(ctx, &flags, &options)
+}
+
+// compress all files in input directory
+func compressBatch(ctx context.Context, flags *compressFlags, options *huffman.EncodeOptions) int {
+	if flags.json {
+		return compressBatchJSON(ctx, flags, options)
+	}
+	fmt.Printf("Batch compressing...\n")
+
+	// batch encode
+	var result huffman.BatchEncodeResult
+	result, err := huffman.BatchEncodeContext(ctx, flags.input, flags.output, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: batch compressing failed:\n%v\n", err)
+		return exitFailure
+	}
+	printBatchErrors("compress", result.Errors, result.Skipped)
+
+	// print summary
+	fmt.Printf("\nBatch compressing completed.\n")
+	if !flags.silent {
+		fmt.Printf("Input path: %s", result.InputPath)
+		fmt.Printf("\nOutput path: %s\n", flags.output)
+		fmt.Printf("Total files: %d\n", result.TotalCount)
+		fmt.Printf("Successful: %d\n", result.SuccessCount)
+		fmt.Printf("Skipped: %d\n", len(result.Skipped))
+		fmt.Printf("Failed: %d\n", len(result.Errors))
+		fmt.Printf("Original total size: %d bytes\n", result.OriginalSize)
+		fmt.Printf("Compressed total size: %d bytes\n", result.EncodedSize)
+		if result.OriginalSize > 0 {
+			var ratio float64 = float64(result.EncodedSize) / float64(result.OriginalSize)
+			fmt.Printf("Compression ratio: %.2f%%\n", ratio*100)
+		}
+		fmt.Printf("Time taken: %.2fs\n", float64(result.Time.Milliseconds())/1000)
+	}
+	return batchExitCode(result.SuccessCount, len(result.Errors))
+}
+
+// compress all files in input directory, print a line of JSON for each file and the summary
+func compressBatchJSON(ctx context.Context, flags *compressFlags, options *huffman.EncodeOptions) int {
+	var w io.Writer = flags.jsonOutput()
+	options.Progress = jsonProgress(w, "compress")
+	result, err := huffman.BatchEncodeContext(ctx, flags.input, flags.output, options)
+	if err != nil && result.InputPath == "" {
+		writeJSON(w, encodeResult("result", flags.input, flags.output, huffman.EncodeSize{}, 0, err))
+		return exitFailure
+	}
+
+	var compressedSize int64 = int64(result.EncodedSize)
+	var compressedRatio float64 = ratio(compressedSize, int64(result.OriginalSize))
+	writeJSON(w, jsonSummary{
+		Type:           "summary",
+		Command:        "compress",
+		Input:          result.InputPath,
+		Output:         result.OutputPath,
+		Total:          result.TotalCount,
+		Successful:     result.SuccessCount,
+		Skipped:        len(result.Skipped),
+		Failed:         len(result.Errors),
+		OriginalSize:   int64(result.OriginalSize),
+		CompressedSize: &compressedSize,
+		Ratio:          &compressedRatio,
+		Seconds:        result.Time.Seconds(),
+	})
+	if err != nil {
+		return exitFailure
+	}
+	return batchExitCode(result.SuccessCount, len(result.Errors))
+}
+
+// compress a single file
+func compressFile(ctx context.Context, flags *compressFlags, options *huffman.EncodeOptions) int {
+	if !flags.json && !flags.streaming() {
+		fmt.Printf("Compressing...\n")
+	}
+
+	// encode file
+	var startTime time.Time = time.Now()
+	encodeSize, encodeTime, err := encodeFile(ctx, flags, options)
+	if flags.json {
+		var result jsonFile = encodeResult("result", flags.input, flags.output, encodeSize, time.Since(startTime), err)
+		if err == nil {
+			var codeGenSeconds float64 = encodeTime.CodeGenTime.Seconds()
+			result.CodeGenSeconds = &codeGenSeconds
+		}
+		writeJSON(flags.jsonOutput(), result)
+		if err != nil {
+			return exitFailure
+		}
+		return exitOK
+	}
+	if err != nil {
+		return fileError("write encoded data failed", err)
+	}
+
+	// print statistic information, data may be on stdout
+	if flags.streaming() {
+		if !flags.silent {
+			printEncodeStatistics(os.Stderr, encodeSize, encodeTime)
+		}
+		return exitOK
+	}
+	fmt.Printf("\nEncode successful, result in: %v\n\n", flags.output)
+	if !flags.silent {
+		printEncodeStatistics(os.Stdout, encodeSize, encodeTime)
+	}
+	return exitOK
+}
+
+// encode input file to output file, "-" for stdin and stdout
+func encodeFile(ctx context.Context, flags *compressFlags, options *huffman.EncodeOptions) (encodeSize huffman.EncodeSize, encodeTime huffman.EncodeTime, err error) {
+	if !flags.streaming() {
+		return huffman.EncodeContext(ctx, flags.input, flags.output, options)
+	}
+	s, err := openStream(flags.input, flags.output, flags.force)
+	if err != nil {
+		return encodeSize, encodeTime, err
+	}
+	defer s.close()
+	encodeSize, encodeTime, err = huffman.EncodeStream(ctx, s.input, s.output, options)
+	if err == nil {
+		err = s.commit()
+	}
+	return encodeSize, encodeTime, err
+}
+
+// print sizes and times of encoding
+func printEncodeStatistics(w io.Writer, encodeSize huffman.EncodeSize, encodeTime huffman.EncodeTime) {
+	// read size information
+	var originalSize int = encodeSize.Original
+	var huffmanTableSize int = encodeSize.HuffmanTable
+	var encodedDataSize int = encodeSize.EncodedData
+	var encodedSize int = huffmanTableSize + encodedDataSize
+
+	// read time information
+	var codeGenTime time.Duration = encodeTime.CodeGenTime
+	var writeTime time.Duration = encodeTime.WriteFileTime
+
+	fmt.Fprintf(w, "Original size: %d bytes\n", originalSize)
+	fmt.Fprintf(w, "Huffman table size: %d bytes\n", huffmanTableSize)
+	fmt.Fprintf(w, "Compressed size (data only): %d bytes\n", encodedDataSize)
+	fmt.Fprintf(w, "Compressed size (with Huffman table): %d bytes\n", encodedSize)
+	if originalSize > 0 {
+		ratio := float64(encodedSize) / float64(originalSize)
+		fmt.Fprintf(w, "Compression ratio: %.2f%%\n", ratio*100)
+	}
+	fmt.Fprintf(w, "Checksum: %v\n\n", encodeSize.Checksum)
+	totalTime := codeGenTime + writeTime
+	fmt.Fprintf(w, "Time: Huffman table generation: %.2fs, File writing: %.2fs, Total: %.2fs\n",
+		float64(codeGenTime.Milliseconds())/1000,
+		float64(writeTime.Milliseconds())/1000,
+		float64(totalTime.Milliseconds())/1000)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Anslen/huffman"
+)
+
+// options of decompress command
+type decompressFlags struct {
+	fileOptions
+	noName    bool
+	special   bool
+	maxOutput int64
+	maxTable  int64
+	maxMemory int64
+}
+
+// register options of decompress command
+func (flags *decompressFlags) optionSet() (set *optionSet) {
+	set = newOptionSet("decompress", "[options] <input>", "Decompress a file, or all files in a directory into an output directory.\n"+
+		"Without -o, output is placed next to input and named by the name stored in the header.")
+	flags.register(set)
+	set.boolOption(&flags.noName, "n", "no-name", "when decoding into a directory, name output after input file instead of stored name")
+	set.boolOption(&flags.special, "", "special-bits", "restore stored setuid, setgid and sticky bits, only permission bits by default")
+	set.sizeOption(&flags.maxOutput, "", "max-output", "max decoded size of each file, e.g. 512M (default no limit)")
+	set.sizeOption(&flags.maxTable, "", "max-table", "max huffman table size, e.g. 1K (default no limit)")
+	set.sizeOption(&flags.maxMemory, "", "max-memory", "max memory of a block, e.g. 64M (default no limit)")
+	return set
+}
+
+// option set of decompress command for help
+func decompressOptions() *optionSet {
+	return new(decompressFlags).optionSet()
+}
+
+// decompress [options] <input>
+func runDecompress(ctx context.Context, args []string) int {
+	var flags decompressFlags
+	var set *optionSet = flags.optionSet()
+	positional, code, ok := parseOptions(set, args)
+	if !ok {
+		return code
+	}
+
+	// directory of input file, named by stored name
+	err := flags.resolve(positional, ".")
+	if err != nil {
+		return usageError(set, err)
+	}
+
+	var options huffman.DecodeOptions
+	options.MaxOutputSize = flags.maxOutput
+	options.MaxTableSize = int(min(flags.maxTable, 1<<31-1))
+	options.MaxMemory = flags.maxMemory
+	options.Force = flags.force
+	options.RemoveInput = flags.remove && !flags.keep // keep wins over remove
+	options.IgnoreName = flags.noName
+	options.SpecialBits = flags.special
+	options.Jobs = flags.jobs
+	options.BatchMemory = flags.batchMemory
+
+	if flags.batch {
+		return decompressBatch(ctx, &flags, &options)
+	}
+	return decompressFile(ctx, &flags, &options)
+}
+
+// decompress all files in input directory
+func decompressBatch(ctx context.Context, flags *decompressFlags, options *huffman.DecodeOptions) int {
+	if flags.json {
+		return decompressBatchJSON(ctx, flags, options)
+	}
+	fmt.Printf("Batch decompressing...\n")
+
+	// batch decode
+	var result huffman.BatchDecodeResult
+	result, err := huffman.BatchDecodeContext(ctx, flags.input, flags.output, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: batch decompressing failed:\n%v\n", err)
+		return exitFailure
+	}
+	printBatchErrors("decompress", result.Errors, result.Skipped)
+
+	// print summary
+	fmt.Printf("\nBatch decompressing completed.\n")
+	if !flags.silent {
+		fmt.Printf("Input path: %s", result.InputPath)
+		fmt.Printf("\nOutput path: %s\n", flags.output)
+		fmt.Printf("Total files: %d\n", result.TotalCount)
+		fmt.Printf("Successful: %d\n", result.SuccessCount)
+		fmt.Printf("Skipped: %d\n", len(result.Skipped))
+		fmt.Printf("Failed: %d\n", len(result.Errors))
+		fmt.Printf("Time taken: %.2fs\n", float64(result.Time.Milliseconds())/1000)
+	}
+	return batchExitCode(result.SuccessCount, len(result.Errors))
+}
+
+// decompress all files in input directory, print a line of JSON for each file and the summary
+func decompressBatchJSON(ctx context.Context, flags *decompressFlags, options *huffman.DecodeOptions) int {
+	var w io.Writer = flags.jsonOutput()
+
+	// sum decoded sizes, batch result has none
+	var originalSize int64 = 0
+	var progress huffman.ProgressFunc = jsonProgress(w, "decompress")
+	options.Progress = func(event huffman.ProgressEvent) {
+		progress(event)
+		if event.Type == huffman.ProgressFileFinished && event.Err == nil {
+			originalSize += int64(event.DecodeSize.Decoded)
+		}
+	}
+	result, err := huffman.BatchDecodeContext(ctx, flags.input, flags.output, options)
+	if err != nil && result.InputPath == "" {
+		writeJSON(w, decodeResult("decompress", "result", flags.input, flags.output, huffman.DecodeSize{}, 0, err))
+		return exitFailure
+	}
+
+	writeJSON(w, jsonSummary{
+		Type:         "summary",
+		Command:      "decompress",
+		Input:        result.InputPath,
+		Output:       result.OutputPath,
+		Total:        result.TotalCount,
+		Successful:   result.SuccessCount,
+		Skipped:      len(result.Skipped),
+		Failed:       len(result.Errors),
+		OriginalSize: originalSize,
+		Seconds:      result.Time.Seconds(),
+	})
+	if err != nil {
+		return exitFailure
+	}
+	return batchExitCode(result.SuccessCount, len(result.Errors))
+}
+
+// decompress a single file
+func decompressFile(ctx context.Context, flags *decompressFlags, options *huffman.DecodeOptions) int {
+	if !flags.json && !flags.streaming() {
+		fmt.Printf("Decompressing...\n")
+	}
+
+	// decode file
+	var startTime time.Time = time.Now()
+	outputPath, decodeSize, decodeTime, err := decodeFile(ctx, flags, options)
+	if flags.json {
+		writeJSON(flags.jsonOutput(), decodeResult("decompress", "result", flags.input, outputPath, decodeSize, time.Since(startTime), err))
+		if err != nil {
+			return exitFailure
+		}
+		return exitOK
+	}
+	if err != nil {
+		return fileError(fmt.Sprintf("failed to decode file %s", flags.input), err)
+	}
+
+	// print statistic information, data may be on stdout
+	if flags.streaming() {
+		if !flags.silent {
+			printDecodeStatistics(os.Stderr, decodeSize, decodeTime)
+		}
+		return exitOK
+	}
+	fmt.Printf("\nDecoded successfully, result in: %s\n", outputPath)
+	if !flags.silent {
+		printDecodeStatistics(os.Stdout, decodeSize, decodeTime)
+	}
+	return exitOK
+}
+
+// decode input file to output file, "-" for stdin and stdout
+//
+// return the output file, resolved in output directory
+func decodeFile(ctx context.Context, flags *decompressFlags, options *huffman.DecodeOptions) (outputPath string, decodeSize huffman.DecodeSize, decodeTime time.Duration, err error) {
+	outputPath = flags.output
+	if !flags.streaming() {
+		outputPath, err = huffman.DecodedPath(flags.input, flags.output, options)
+		if err != nil {
+			return flags.output, decodeSize, decodeTime, err
+		}
+		decodeSize, decodeTime, err = huffman.DecodeContext(ctx, flags.input, outputPath, options)
+		return outputPath, decodeSize, decodeTime, err
+	}
+	s, err := openStream(flags.input, flags.output, flags.force)
+	if err != nil {
+		return outputPath, decodeSize, decodeTime, err
+	}
+	defer s.close()
+	decodeSize, decodeTime, err = huffman.DecodeStream(ctx, s.input, s.output, options)
+	if err == nil {
+		err = s.commit()
+	}
+	return outputPath, decodeSize, decodeTime, err
+}
+
+// print sizes, checksum and time of decoding
+func printDecodeStatistics(w io.Writer, decodeSize huffman.DecodeSize, decodeTime time.Duration) {
+	fmt.Fprintf(w, "Original size: %d bytes\n", decodeSize.Original)
+	fmt.Fprintf(w, "Decompressed size: %d bytes\n", decodeSize.Decoded)
+	if decodeSize.Checksum.Algorithm == huffman.ChecksumNone {
+		fmt.Fprintf(w, "Checksum: none\n")
+	} else {
+		fmt.Fprintf(w, "Checksum: %v (verified)\n", decodeSize.Checksum)
+	}
+	fmt.Fprintf(w, "Time: Decoding: %.2fs\n", float64(decodeTime.Milliseconds())/1000)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Anslen/huffman"
+)
+
+// options of info command
+type infoFlags struct {
+	summary bool
+	json    bool
+}
+
+// register options of info command
+func (flags *infoFlags) optionSet() (set *optionSet) {
+	set = newOptionSet("info", "[options] <file|directory>...", "Show format, sizes and huffman codes of compressed files without decoding them,\n"+
+		"directories are inspected recursively.")
+	set.boolOption(&flags.summary, "", "summary", "do not print huffman codes of each block")
+	set.boolOption(&flags.json, "", "json", "print one JSON object per file and line")
+	return set
+}
+
+// option set of info command for help
+func infoOptions() *optionSet {
+	return new(infoFlags).optionSet()
+}
+
+// info of a file as JSON
+type jsonInfo struct {
+	Path         string      `json:"path"`
+	Version      int         `json:"version,omitempty"`
+	HeaderSize   int64       `json:"header_size"`
+	Size         int64       `json:"size"`
+	OriginalSize int64       `json:"original_size"` // -1 if unknown
+	TableSize    int64       `json:"table_size"`
+	DataSize     int64       `json:"data_size"`
+	Ratio        float64     `json:"ratio"`
+	Checksum     string      `json:"checksum,omitempty"`
+	Name         string      `json:"name,omitempty"`
+	Mode         string      `json:"mode,omitempty"`
+	ModTime      *time.Time  `json:"mod_time,omitempty"`
+	AccessTime   *time.Time  `json:"access_time,omitempty"`
+	Blocks       []jsonBlock `json:"blocks,omitempty"`
+	Error        *jsonError  `json:"error,omitempty"`
+}
+
+// block of a file as JSON
+type jsonBlock struct {
+	Offset       int64      `json:"offset"`
+	OriginalSize int64      `json:"original_size"` // -1 if unknown
+	Symbols      int        `json:"symbols"`
+	TableSize    int        `json:"table_size"`
+	DataBits     uint64     `json:"data_bits"`
+	DataSize     int64      `json:"data_size"`
+	Codes        []jsonCode `json:"codes,omitempty"`
+}
+
+// huffman code of a symbol as JSON
+type jsonCode struct {
+	Symbol byte   `json:"symbol"`
+	Char   string `json:"char"`
+	Width  uint8  `json:"width"`
+	Code   string `json:"code"`
+}
+
+// info <file|directory>...
+func runInfo(ctx context.Context, args []string) int {
+	var flags infoFlags
+	var set *optionSet = flags.optionSet()
+	positional, code, ok := parseOptions(set, args)
+	if !ok {
+		return code
+	}
+	if len(positional) == 0 {
+		return usageError(set, fmt.Errorf("input file required"))
+	}
+
+	paths, errorCount := collectFiles(positional)
+	var successCount int = 0
+	for index, path := range paths {
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "Error: info canceled: %v\n", ctx.Err())
+			return exitFailure
+		}
+		path = filepath.Clean(path)
+		info, err := readInfo(path)
+		if err != nil {
+			errorCount++
+		} else {
+			successCount++
+		}
+
+		if flags.json {
+			writeJSON(os.Stdout, infoJSON(path, info, err, !flags.summary))
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: read %s failed: %v\n", path, err)
+			continue
+		}
+		if index > 0 {
+			fmt.Println()
+		}
+		printInfo(os.Stdout, path, info, !flags.summary)
+	}
+	return batchExitCode(successCount, errorCount)
+}
+
+// read info of compressed file
+func readInfo(path string) (info huffman.Info, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return info, err
+	}
+	defer file.Close()
+	return huffman.ReadInfo(file)
+}
+
+// print info as human readable text
+func printInfo(w io.Writer, path string, info huffman.Info, codes bool) {
+	var header huffman.Header = info.Header
+	fmt.Fprintf(w, "File: %s\n", path)
+	if header.Version == huffman.LegacyVersion {
+		fmt.Fprintf(w, "Format: version %d (legacy, no header)\n", header.Version)
+	} else {
+		fmt.Fprintf(w, "Format: version %d, header %d bytes\n", header.Version, info.HeaderSize)
+	}
+	if header.Name != "" {
+		fmt.Fprintf(w, "Name: %s\n", header.Name)
+	}
+	if header.Mode != 0 {
+		fmt.Fprintf(w, "Mode: %v\n", header.Mode)
+	}
+	if !header.ModTime.IsZero() {
+		fmt.Fprintf(w, "Modification time: %v\n", header.ModTime.Format(time.RFC3339Nano))
+	}
+	if !header.AccessTime.IsZero() {
+		fmt.Fprintf(w, "Access time: %v\n", header.AccessTime.Format(time.RFC3339Nano))
+	}
+	fmt.Fprintf(w, "Checksum: %v\n", info.Checksum)
+
+	// sizes
+	var originalSize int64 = info.OriginalSize()
+	if originalSize >= 0 {
+		fmt.Fprintf(w, "Original size: %d bytes\n", originalSize)
+	} else {
+		fmt.Fprintf(w, "Original size: unknown\n")
+	}
+	fmt.Fprintf(w, "Compressed size: %d bytes\n", info.Size)
+	fmt.Fprintf(w, "Huffman tables and headers: %d bytes (%.2f%%)\n", info.TableSize(), percent(info.TableSize(), info.Size))
+	fmt.Fprintf(w, "Encoded data: %d bytes (%.2f%%)\n", info.DataSize(), percent(info.DataSize(), info.Size))
+	if originalSize > 0 {
+		fmt.Fprintf(w, "Compression ratio: %.2f%%\n", percent(info.Size, originalSize))
+	}
+	fmt.Fprintf(w, "Blocks: %d\n", len(info.Blocks))
+
+	// blocks
+	for index, block := range info.Blocks {
+		var blockSize string = "unknown"
+		if block.Size >= 0 {
+			blockSize = fmt.Sprintf("%d bytes", block.Size)
+		}
+		fmt.Fprintf(w, "\nBlock %d at offset %d: original %s, %d symbols, table %d bytes, encoded data %d bits (%d bytes)\n",
+			index, block.Offset, blockSize, len(block.Codes), block.TableSize, block.DataWidth, block.DataSize)
+		if !codes || len(block.Codes) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %-6s %5s  %s\n", "symbol", "width", "code")
+		for _, char := range sortedSymbols(block.Codes) {
+			var code huffman.HuffmanCode = block.Codes[char]
+			fmt.Fprintf(w, "  %-6s %5d  %s\n", symbolName(char), code.Width, codeString(code))
+		}
+	}
+}
+
+// info as JSON, codes of blocks are included if codes is set
+func infoJSON(path string, info huffman.Info, err error, codes bool) (ret jsonInfo) {
+	ret = jsonInfo{Path: path, Error: newJSONError(err)}
+	if err != nil {
+		return ret
+	}
+	var header huffman.Header = info.Header
+	ret.Version = header.Version
+	ret.HeaderSize = info.HeaderSize
+	ret.Size = info.Size
+	ret.OriginalSize = info.OriginalSize()
+	ret.TableSize = info.TableSize()
+	ret.DataSize = info.DataSize()
+	if ret.OriginalSize > 0 {
+		ret.Ratio = ratio(info.Size, ret.OriginalSize)
+	}
+	ret.Checksum = info.Checksum.String()
+	ret.Name = header.Name
+	if header.Mode != 0 {
+		ret.Mode = fmt.Sprintf("%#o", uint32(header.Mode.Perm()))
+	}
+	if !header.ModTime.IsZero() {
+		ret.ModTime = &header.ModTime
+	}
+	if !header.AccessTime.IsZero() {
+		ret.AccessTime = &header.AccessTime
+	}
+	ret.Blocks = make([]jsonBlock, 0, len(info.Blocks))
+	for _, block := range info.Blocks {
+		var jsonBlock jsonBlock = jsonBlock{
+			Offset:       block.Offset,
+			OriginalSize: block.Size,
+			Symbols:      len(block.Codes),
+			TableSize:    block.TableSize,
+			DataBits:     block.DataWidth,
+			DataSize:     block.DataSize,
+		}
+		if codes {
+			for _, char := range sortedSymbols(block.Codes) {
+				var code huffman.HuffmanCode = block.Codes[char]
+				jsonBlock.Codes = append(jsonBlock.Codes, jsonCode{Symbol: char, Char: symbolName(char), Width: code.Width, Code: codeString(code)})
+			}
+		}
+		ret.Blocks = append(ret.Blocks, jsonBlock)
+	}
+	return ret
+}
+
+// symbols of codes in canonical order, by width then by symbol
+func sortedSymbols(codes huffman.HuffmanCodes) (symbols []byte) {
+	symbols = make([]byte, 0, len(codes))
+	for char := range codes {
+		symbols = append(symbols, char)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if codes[symbols[i]].Width != codes[symbols[j]].Width {
+			return codes[symbols[i]].Width < codes[symbols[j]].Width
+		}
+		return symbols[i] < symbols[j]
+	})
+	return symbols
+}
+
+// printable character quoted, others escaped as \xNN
+func symbolName(char byte) string {
+	if char >= 0x21 && char <= 0x7e {
+		return fmt.Sprintf("'%c'", char)
+	}
+	switch char {
+	case ' ':
+		return "' '"
+	case '\n':
+		return `\n`
+	case '\r':
+		return `\r`
+	case '\t':
+		return `\t`
+	}
+	return fmt.Sprintf(`\x%02x`, char)
+}
+
+// code as bits, highest bit first
+func codeString(code huffman.HuffmanCode) string {
+	return fmt.Sprintf("%0*b", int(code.Width), code.Code)
+}
+
+// part of whole in percent, 0 if whole is 0
+func percent(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
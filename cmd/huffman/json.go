@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/Anslen/huffman"
+)
+
+const JSON_SCHEMA_STRING = "JSON output (--json):\n" +
+	"  single file: one object of type \"result\"\n" +
+	"  batch mode:  one object of type \"file\" per line as each file finishes,\n" +
+	"               then one object of type \"summary\" (NDJSON)\n" +
+	"  JSON goes to stdout, or stderr when data is written to stdout\n" +
+	"\n" +
+	"result, file:\n" +
+	"  type              \"result\" or \"file\"\n" +
+	"  command           \"compress\", \"decompress\" or \"test\"\n" +
+	"  input             input path, \"-\" for stdin\n" +
+	"  output            output path, \"-\" for stdout, omitted if not known or for test\n" +
+	"  status            \"ok\", \"skipped\" (output exists) or \"failed\"\n" +
+	"  original_size     uncompressed size in bytes\n" +
+	"  compressed_size   compressed size in bytes, with headers and tables\n" +
+	"  table_size        headers and huffman tables in bytes, compress only\n" +
+	"  data_size         encoded data in bytes, compress only\n" +
+	"  ratio             compressed_size / original_size, 0 if original_size is 0\n" +
+	"  checksum          \"algorithm:hex\" or \"none\", omitted on failure\n" +
+	"  seconds           time spent on the file\n" +
+	"  code_gen_seconds  time spent on huffman table generation, compress result only\n" +
+	"  error             error object, omitted on success\n" +
+	"\n" +
+	"summary:\n" +
+	"  type              \"summary\"\n" +
+	"  command, input, output  as above\n" +
+	"  total             number of files found\n" +
+	"  successful, skipped, failed  number of files\n" +
+	"  original_size     uncompressed size of successful files in bytes\n" +
+	"  compressed_size   compressed size of successful files in bytes, compress only\n" +
+	"  ratio             compressed_size / original_size, compress only\n" +
+	"  seconds           time spent on the batch\n" +
+	"\n" +
+	"error:\n" +
+	"  code     not_huffman, unsupported_version, checksum_mismatch, corrupt_metadata,\n" +
+	"           corrupt_table, truncated_data, invalid_code, size_mismatch, output_exists,\n" +
+	"           verify_mismatch, no_checksum, output_collision, limit_exceeded, canceled,\n" +
+	"           not_found, permission or error\n" +
+	"  message  error text\n" +
+	"  offset   offset of the header or block containing the error, format errors only\n" +
+	"  limit    name of the exceeded limit, limit errors only"
+
+// result of a file, see JSON_SCHEMA_STRING
+type jsonFile struct {
+	Type           string     `json:"type"`
+	Command        string     `json:"command"`
+	Input          string     `json:"input"`
+	Output         string     `json:"output,omitempty"`
+	Status         string     `json:"status"`
+	OriginalSize   int64      `json:"original_size"`
+	CompressedSize int64      `json:"compressed_size"`
+	TableSize      *int64     `json:"table_size,omitempty"`
+	DataSize       *int64     `json:"data_size,omitempty"`
+	Ratio          float64    `json:"ratio"`
+	Checksum       string     `json:"checksum,omitempty"`
+	Seconds        float64    `json:"seconds"`
+	CodeGenSeconds *float64   `json:"code_gen_seconds,omitempty"`
+	Error          *jsonError `json:"error,omitempty"`
+}
+
+// summary of a batch, see JSON_SCHEMA_STRING
+type jsonSummary struct {
+	Type           string   `json:"type"`
+	Command        string   `json:"command"`
+	Input          string   `json:"input"`
+	Output         string   `json:"output,omitempty"`
+	Total          int      `json:"total"`
+	Successful     int      `json:"successful"`
+	Skipped        int      `json:"skipped"`
+	Failed         int      `json:"failed"`
+	OriginalSize   int64    `json:"original_size"`
+	CompressedSize *int64   `json:"compressed_size,omitempty"`
+	Ratio          *float64 `json:"ratio,omitempty"`
+	Seconds        float64  `json:"seconds"`
+}
+
+// structured error, see JSON_SCHEMA_STRING
+type jsonError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Offset  *int64 `json:"offset,omitempty"`
+	Limit   string `json:"limit,omitempty"`
+}
+
+// codes of errors, first match wins
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{huffman.ErrNotHuffman, "not_huffman"},
+	{huffman.ErrUnsupportedVersion, "unsupported_version"},
+	{huffman.ErrChecksumMismatch, "checksum_mismatch"},
+	{huffman.ErrCorruptMetadata, "corrupt_metadata"},
+	{huffman.ErrCorruptTable, "corrupt_table"},
+	{huffman.ErrTruncatedData, "truncated_data"},
+	{huffman.ErrInvalidCode, "invalid_code"},
+	{huffman.ErrSizeMismatch, "size_mismatch"},
+	{huffman.ErrOutputExists, "output_exists"},
+	{huffman.ErrVerifyMismatch, "verify_mismatch"},
+	{huffman.ErrNoChecksum, "no_checksum"},
+	{huffman.ErrOutputCollision, "output_collision"},
+	{huffman.ErrLimitExceeded, "limit_exceeded"},
+	{context.Canceled, "canceled"},
+	{context.DeadlineExceeded, "canceled"},
+	{fs.ErrNotExist, "not_found"},
+	{fs.ErrPermission, "permission"},
+}
+
+// convert err to structured error, nil if err is nil
+func newJSONError(err error) (ret *jsonError) {
+	if err == nil {
+		return nil
+	}
+	ret = &jsonError{Code: "error", Message: err.Error()}
+	for _, errorCode := range errorCodes {
+		if errors.Is(err, errorCode.err) {
+			ret.Code = errorCode.code
+			break
+		}
+	}
+	var formatErr *huffman.FormatError
+	if errors.As(err, &formatErr) {
+		ret.Offset = &formatErr.Offset
+	}
+	var limitErr *huffman.LimitError
+	if errors.As(err, &limitErr) {
+		ret.Limit = limitErr.Limit
+	}
+	return ret
+}
+
+// status of a finished file
+func fileStatus(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, huffman.ErrOutputExists):
+		return "skipped"
+	}
+	return "failed"
+}
+
+// compressed size / original size, 0 if original size is 0
+func ratio(compressed, original int64) float64 {
+	if original == 0 {
+		return 0
+	}
+	return float64(compressed) / float64(original)
+}
+
+// write value as one line of JSON
+func writeJSON(w io.Writer, value any) {
+	json.NewEncoder(w).Encode(value)
+}
+
+// result of encoding a file
+func encodeResult(fileType, input, output string, encodeSize huffman.EncodeSize, duration time.Duration, err error) (ret jsonFile) {
+	ret = jsonFile{Type: fileType, Command: "compress", Input: input, Output: output, Status: fileStatus(err), Seconds: duration.Seconds()}
+	ret.Error = newJSONError(err)
+	if err != nil {
+		return ret
+	}
+	var tableSize, dataSize int64 = int64(encodeSize.HuffmanTable), int64(encodeSize.EncodedData)
+	ret.OriginalSize = int64(encodeSize.Original)
+	ret.CompressedSize = tableSize + dataSize
+	ret.TableSize = &tableSize
+	ret.DataSize = &dataSize
+	ret.Ratio = ratio(ret.CompressedSize, ret.OriginalSize)
+	ret.Checksum = encodeSize.Checksum.String()
+	return ret
+}
+
+// result of decoding a file
+func decodeResult(command, fileType, input, output string, decodeSize huffman.DecodeSize, duration time.Duration, err error) (ret jsonFile) {
+	ret = jsonFile{Type: fileType, Command: command, Input: input, Output: output, Status: fileStatus(err), Seconds: duration.Seconds()}
+	ret.Error = newJSONError(err)
+	if err != nil {
+		return ret
+	}
+	ret.OriginalSize = int64(decodeSize.Decoded)
+	ret.CompressedSize = int64(decodeSize.Original)
+	ret.Ratio = ratio(ret.CompressedSize, ret.OriginalSize)
+	ret.Checksum = decodeSize.Checksum.String()
+	return ret
+}
+
+// progress callback writing a line of JSON for each finished file
+func jsonProgress(w io.Writer, command string) huffman.ProgressFunc {
+	return func(event huffman.ProgressEvent) {
+		if event.Type != huffman.ProgressFileFinished {
+			return
+		}
+		if command == "compress" {
+			writeJSON(w, encodeResult("file", event.Path, event.OutputPath, event.EncodeSize, event.Time, event.Err))
+		} else {
+			writeJSON(w, decodeResult(command, "file", event.Path, event.OutputPath, event.DecodeSize, event.Time, event.Err))
+		}
+	}
+}
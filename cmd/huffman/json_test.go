@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// --json prints results and summaries following JSON_SCHEMA_STRING
+func TestJSONOutput(t *testing.T) {
+	var dir string = t.TempDir()
+	var text []byte = bytes.Repeat([]byte("json output\n"), 100)
+	var input string = filepath.Join(dir, "a.txt")
+	var batch string = filepath.Join(dir, "batch")
+	var encoded string = filepath.Join(batch, "a.bin")
+	if err := os.WriteFile(input, text, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// single file, sizes add up to the written file
+	output, code := runPiped(t, nil, "compress", "--json", "-o", encoded, input)
+	var result jsonFile
+	if err := json.Unmarshal(output, &result); err != nil || code != exitOK {
+		t.Fatalf("compress: exit code %d, %v: %s", code, err, output)
+	}
+	info, err := os.Stat(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Type != "result" || result.Command != "compress" || result.Status != "ok" || result.Error != nil ||
+		result.OriginalSize != int64(len(text)) || result.CompressedSize != info.Size() ||
+		result.TableSize == nil || result.DataSize == nil || *result.TableSize+*result.DataSize != info.Size() {
+		t.Fatalf("compress result %s", output)
+	}
+
+	// batch, one line per file and a summary, errors with code and offset
+	if err = os.WriteFile(filepath.Join(batch, "zeros.bin"), make([]byte, 99), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output, code = runPiped(t, nil, "decompress", "--json", "-o", filepath.Join(dir, "decoded"), batch)
+	if code != exitPartial {
+		t.Fatalf("decompress: exit code %d", code)
+	}
+	var lines []map[string]any
+	var scanner *bufio.Scanner = bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		var line map[string]any
+		if err = json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid line %s: %v", scanner.Bytes(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("%d lines, want 2 files and summary: %s", len(lines), output)
+	}
+	var statuses map[string]string = make(map[string]string)
+	for _, line := range lines[:2] {
+		if line["type"] != "file" {
+			t.Fatalf("line of type %v, want file", line["type"])
+		}
+		statuses[filepath.Base(line["input"].(string))] = line["status"].(string)
+		if line["status"] == "failed" {
+			var jsonErr map[string]any = line["error"].(map[string]any)
+			if jsonErr["code"] != "not_huffman" || jsonErr["offset"] == nil {
+				t.Fatalf("error %v, want not_huffman with offset", jsonErr)
+			}
+		}
+	}
+	if statuses["a.bin"] != "ok" || statuses["zeros.bin"] != "failed" {
+		t.Fatalf("statuses %v", statuses)
+	}
+	var summary map[string]any = lines[2]
+	if summary["type"] != "summary" || summary["total"] != 2.0 || summary["successful"] != 1.0 || summary["failed"] != 1.0 || summary["original_size"] != float64(len(text)) {
+		t.Fatalf("summary %v", summary)
+	}
+}
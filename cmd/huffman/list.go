@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Anslen/huffman"
+)
+
+// options of list command
+type listFlags struct{}
+
+// register options of list command
+func (flags *listFlags) optionSet() (set *optionSet) {
+	return newOptionSet("list", "<file|directory>...", "List compressed size, original size, ratio, checksum algorithm and stored name\n"+
+		"of compressed files, directories are listed recursively.")
+}
+
+// option set of list command for help
+func listOptions() *optionSet {
+	return new(listFlags).optionSet()
+}
+
+// list <file|directory>...
+func runList(ctx context.Context, args []string) int {
+	var flags listFlags
+	var set *optionSet = flags.optionSet()
+	positional, code, ok := parseOptions(set, args)
+	if !ok {
+		return code
+	}
+	if len(positional) == 0 {
+		return usageError(set, fmt.Errorf("input file required"))
+	}
+
+	// collect files of all arguments
+	paths, errorCount := collectFiles(positional)
+
+	var successCount int = 0
+	var compressedTotal, originalTotal int64 = 0, 0
+	fmt.Printf("%12s %12s %8s  %-8s  %-20s  %s\n", "compressed", "original", "ratio", "checksum", "name", "path")
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "Error: list canceled: %v\n", ctx.Err())
+			return exitFailure
+		}
+		compressed, header, err := readListHeader(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: read header of %s failed: %v\n", path, err)
+			errorCount++
+			continue
+		}
+		successCount++
+		compressedTotal += compressed
+
+		var original, ratio string = "-", "-"
+		if header.Size >= 0 {
+			originalTotal += header.Size
+			original = fmt.Sprint(header.Size)
+			if header.Size > 0 {
+				ratio = fmt.Sprintf("%.2f%%", float64(compressed)/float64(header.Size)*100)
+			}
+		}
+		var name string = header.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Printf("%12d %12s %8s  %-8v  %-20s  %s\n", compressed, original, ratio, header.Checksum, name, filepath.Clean(path))
+	}
+	if successCount > 1 {
+		fmt.Printf("%12d %12d %8s  %d files\n", compressedTotal, originalTotal, "", successCount)
+	}
+	return batchExitCode(successCount, errorCount)
+}
+
+// collect files of arguments, directories recursively
+//
+// errors are printed, return files and number of errors
+func collectFiles(args []string) (paths []string, errorCount int) {
+	for _, path := range args {
+		files, batchErrors, err := huffman.GetFilesInDir(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			errorCount++
+			continue
+		}
+		for _, batchErr := range batchErrors {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", batchErr)
+			errorCount++
+		}
+		paths = append(paths, files...)
+	}
+	return paths, errorCount
+}
+
+// read size and header of compressed file
+func readListHeader(path string) (compressed int64, header huffman.Header, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, header, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, header, err
+	}
+	header, err = huffman.NewReader(file).Header()
+	return info.Size(), header, err
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Anslen/huffman"
+)
+
+// exit codes
+const (
+	exitOK      = 0 // success
+	exitFailure = 1 // failed, or every file failed in batch mode
+	exitUsage   = 2 // invalid command or options
+	exitPartial = 3 // some files failed in batch mode, others succeeded
+)
+
+const EXIT_CODE_STRING = "Exit codes:\n" +
+	"  0  success, skipped existing outputs are not failures\n" +
+	"  1  failure, or every file failed in batch mode\n" +
+	"  2  invalid command or options\n" +
+	"  3  some files failed in batch mode, others succeeded"
+
+// subcommand of the CLI
+type command struct {
+	names   []string // name and aliases
+	summary string
+	options func() *optionSet // nil if command has no options
+	run     func(ctx context.Context, args []string) int
+}
+
+// all commands in help order
+func commands() []command {
+	return []command{
+		{names: []string{"compress", "zip"}, summary: "compress a file or all files in a directory", options: compressOptions, run: runCompress},
+		{names: []string{"decompress", "unzip"}, summary: "decompress a file or all files in a directory", options: decompressOptions, run: runDecompress},
+		{names: []string{"test", "verify"}, summary: "check compressed files by decoding them without writing output", options: testOptions, run: runTest},
+		{names: []string{"list"}, summary: "list sizes, names and checksums of compressed files", options: listOptions, run: runList},
+		{names: []string{"info"}, summary: "show format, sizes and huffman codes of compressed files", options: infoOptions, run: runInfo},
+		{names: []string{"analyze"}, summary: "report entropy and the predicted compressed size of files", options: analyzeOptions, run: runAnalyze},
+		{names: []string{"tree"}, summary: "export the huffman tree of a file as Graphviz DOT or JSON", options: treeOptions, run: runTree},
+		{names: []string{"help"}, summary: "display help of a command", run: runHelp},
+	}
+}
+
+// find command by name or alias
+func findCommand(name string) (ret command, ok bool) {
+	for _, cmd := range commands() {
+		for _, cmdName := range cmd.names {
+			if cmdName == name {
+				return cmd, true
+			}
+		}
+	}
+	return ret, false
+}
+
+// print usage of the CLI
+func printUsage(file *os.File) {
+	fmt.Fprintf(file, "Usage: huffman <command> [options] [input]\n\nCommands:\n")
+	var width int = 0
+	for _, cmd := range commands() {
+		width = max(width, len(strings.Join(cmd.names, ", ")))
+	}
+	for _, cmd := range commands() {
+		fmt.Fprintf(file, "  %-*s  %s\n", width, strings.Join(cmd.names, ", "), cmd.summary)
+	}
+	fmt.Fprintf(file, "\nRun 'huffman help <command>' for options of a command,\n")
+	fmt.Fprintf(file, "'huffman help json' for the schema of JSON output.\n\n%s\n", EXIT_CODE_STRING)
+}
+
+// help [command]
+func runHelp(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return exitOK
+	}
+	if args[0] == "json" {
+		fmt.Println(JSON_SCHEMA_STRING)
+		return exitOK
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		return usageError(nil, fmt.Errorf("unknown command %s", args[0]))
+	}
+	if cmd.options == nil {
+		fmt.Printf("Usage: huffman %s\n\n%s\n", cmd.names[0], cmd.summary)
+		return exitOK
+	}
+	cmd.options().printUsage(os.Stdout)
+	fmt.Printf("\n%s\n", EXIT_CODE_STRING)
+	return exitOK
+}
+
+// report usage error, return exitUsage
+func usageError(set *optionSet, err error) int {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	if set != nil {
+		fmt.Fprintf(os.Stderr, "Run 'huffman help %s' for usage.\n", set.flags.Name())
+	} else {
+		fmt.Fprintf(os.Stderr, "Run 'huffman help' for usage.\n")
+	}
+	return exitUsage
+}
+
+// parse options of command, return exit code and false if command should not run
+func parseOptions(set *optionSet, args []string) (positional []string, code int, ok bool) {
+	positional, err := set.parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		set.printUsage(os.Stdout)
+		return nil, exitOK, false
+	}
+	if err != nil {
+		return nil, usageError(set, err), false
+	}
+	return positional, exitOK, true
+}
+
+// exit code of batch result
+func batchExitCode(successCount, errorCount int) int {
+	if errorCount == 0 {
+		return exitOK
+	}
+	if successCount == 0 {
+		return exitFailure
+	}
+	return exitPartial
+}
+
+// parseSize parses size with optional K, M, G suffix (powers of 1024)
+func parseSize(str string) (size int64, err error) {
+	var unit int64 = 1
+	switch {
+	case strings.HasSuffix(str, "K"), strings.HasSuffix(str, "k"):
+		unit = 1 << 10
+	case strings.HasSuffix(str, "M"), strings.HasSuffix(str, "m"):
+		unit = 1 << 20
+	case strings.HasSuffix(str, "G"), strings.HasSuffix(str, "g"):
+		unit = 1 << 30
+	}
+	if unit != 1 {
+		str = str[:len(str)-1]
+	}
+	size, err = strconv.ParseInt(str, 10, 64)
+	if err != nil || size <= 0 || size > (1<<63-1)/unit {
+		return 0, fmt.Errorf("invalid size %s", str)
+	}
+	return size * unit, nil
+}
+
+// processPath converts relative output path to absolute path in the same directory as input file
+//
+// if inputPath is absolute and outputPath is relative, place output file in input file's directory
+func processPath(inputPath, outputPath string) (string, string) {
+
+	// Clean the paths
+	inputPath = filepath.Clean(inputPath)
+
+	if !filepath.IsAbs(outputPath) {
+		inputDir := filepath.Dir(inputPath)
+		outputAbsPath := filepath.Join(inputDir, outputPath)
+		return inputPath, outputAbsPath
+	} else {
+		outputPath = filepath.Clean(outputPath)
+		return inputPath, outputPath
+	}
+}
+
+// options shared by compress and decompress
+type fileOptions struct {
+	input       string
+	output      string
+	stdout      bool
+	batch       bool
+	silent      bool
+	json        bool
+	force       bool
+	keep        bool
+	remove      bool
+	jobs        int
+	batchMemory int64
+}
+
+// register shared options
+func (options *fileOptions) register(set *optionSet) {
+	set.stringOption(&options.input, "i", "input", "path", "input file, or directory in batch mode (or pass as argument), - for stdin (default if stdin is piped)")
+	set.stringOption(&options.output, "o", "output", "path", "output file or directory, relative to input directory (optional), - for stdout")
+	set.boolOption(&options.stdout, "c", "stdout", "write output to stdout, same as -o -, input defaults to stdin")
+	set.boolOption(&options.batch, "b", "batch", "batch mode, process all files in input directory (default if input is a directory)")
+	set.boolOption(&options.silent, "s", "silent", "silent mode, do not print statistic information")
+	set.boolOption(&options.json, "", "json", "print results as JSON, one object per line in batch mode, see 'huffman help json'")
+	set.boolOption(&options.force, "f", "force", "overwrite existing output files")
+	set.boolOption(&options.keep, "k", "keep", "keep input files (default)")
+	set.boolOption(&options.remove, "", "rm", "remove input files after successful and verified processing")
+	set.intOption(&options.jobs, "j", "jobs", "n", "number of files processed at the same time in batch mode (default number of CPUs)", 1, 1<<16)
+	set.sizeOption(&options.batchMemory, "", "batch-memory", "memory budget of files processed at the same time in batch mode, e.g. 1G (default no limit)")
+}
+
+// resolve input and output paths from options and positional arguments
+//
+// defaultOutput is used when no output is given, the input directory in batch mode,
+// stdout if input is stdin
+func (options *fileOptions) resolve(positional []string, defaultOutput string) (err error) {
+	if len(positional) > 1 || (len(positional) == 1 && options.input != "") {
+		return fmt.Errorf("only one input allowed")
+	}
+	if len(positional) == 1 {
+		options.input = positional[0]
+	}
+	if options.stdout {
+		if options.output != "" && options.output != "-" {
+			return fmt.Errorf("-c and -o %s can not be used together", options.output)
+		}
+		options.output = "-"
+	}
+	if options.input == "" && (options.output == "-" || stdinPiped()) {
+		options.input = "-"
+	}
+	if options.input == "" {
+		return fmt.Errorf("input file required")
+	}
+	if options.input == "-" && options.output == "" {
+		options.output = "-"
+	}
+
+	// stdin or stdout, one file only
+	if options.streaming() {
+		if options.batch {
+			return fmt.Errorf("batch mode can not read stdin or write stdout")
+		}
+		if options.remove && !options.keep {
+			return fmt.Errorf("--rm can not be used with stdin or stdout")
+		}
+		if options.input != "-" {
+			options.input = filepath.Clean(options.input)
+		}
+		if options.output != "-" {
+			options.output, err = filepath.Abs(options.output)
+			if err != nil {
+				return fmt.Errorf("invalid output path %s: %w", options.output, err)
+			}
+		}
+		return nil
+	}
+
+	options.input, err = filepath.Abs(options.input)
+	if err != nil {
+		return fmt.Errorf("invalid input path %s: %w", options.input, err)
+	}
+	if info, statErr := os.Stat(options.input); statErr == nil && info.IsDir() {
+		options.batch = true
+	}
+
+	// default output path
+	if options.output == "" {
+		if options.batch {
+			options.output = options.input
+		} else {
+			options.output = defaultOutput
+		}
+	}
+
+	// Convert relative output path to absolute if input is absolute
+	options.input, options.output = processPath(options.input, options.output)
+	return nil
+}
+
+// writer of JSON output, stderr if data is written to stdout
+func (options *fileOptions) jsonOutput() io.Writer {
+	if options.output == "-" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// input is stdin or output is stdout
+func (options *fileOptions) streaming() bool {
+	return options.input == "-" || options.output == "-"
+}
+
+// stdin is a pipe or file, not a terminal
+func stdinPiped() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+// print errors and skipped files of batch result
+func printBatchErrors(verb string, batchErrors []huffman.BatchError, skipped []huffman.BatchError) {
+	for _, batchErr := range batchErrors {
+		fmt.Fprintf(os.Stderr, "Error: %s file %s failed:\n%v\n", verb, batchErr.Path, batchErr.Err)
+	}
+	for _, skippedErr := range skipped {
+		fmt.Fprintf(os.Stderr, "Skipped: %s: %v\n", skippedErr.Path, skippedErr.Err)
+	}
+}
+
+// print error of a single file, return exitFailure
+func fileError(message string, err error) int {
+	fmt.Fprintf(os.Stderr, "Error: %s:\n%v\n", message, err)
+	if errors.Is(err, huffman.ErrOutputExists) {
+		fmt.Fprintln(os.Stderr, "use -f to overwrite")
+	}
+	return exitFailure
+}
+
+func main() {
+	// stop on interrupt, partial outputs are removed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	var code int = run(ctx, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
+
+// run the command in args, return exit code
+func run(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+	if args[0] == "-h" || args[0] == "--help" {
+		printUsage(os.Stdout)
+		return exitOK
+	}
+
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		return usageError(nil, fmt.Errorf("unknown command %s", args[0]))
+	}
+	return cmd.run(ctx, args[1:])
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// redirect stdout and stderr of commands to a file until the test ends, return the file
+func captureOutput(t *testing.T) (output *os.File) {
+	output, err := os.CreateTemp(t.TempDir(), "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr *os.File = os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = output, output
+	t.Cleanup(func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		output.Close()
+	})
+	return output
+}
+
+// commands exit with 0 on success, 1 on failure, 2 on usage errors and 3 on partial batch failure
+func TestExitCodes(t *testing.T) {
+	var dir string = t.TempDir()
+	var text string = filepath.Join(dir, "a.txt")
+	var batch string = filepath.Join(dir, "batch")
+	var bad string = filepath.Join(dir, "bad")
+	if err := os.WriteFile(text, []byte("hello, huffman"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{batch, bad} {
+		if err := os.Mkdir(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "zeros.bin"), make([]byte, 99), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		args []string
+		code int
+	}{
+		{nil, exitUsage},
+		{[]string{"--help"}, exitOK},
+		{[]string{"unknown"}, exitUsage},
+		{[]string{"help", "compress"}, exitOK},
+		{[]string{"compress", "--unknown", text}, exitUsage},
+		{[]string{"compress", "-s", "-o", filepath.Join(batch, "a.bin"), text}, exitOK},
+		{[]string{"compress", "-s", "-o", filepath.Join(batch, "a.bin"), text}, exitFailure},
+		{[]string{"compress", "-s", filepath.Join(dir, "missing.txt")}, exitFailure},
+		{[]string{"test", filepath.Join(batch, "a.bin")}, exitOK},
+		{[]string{"test", filepath.Join(bad, "zeros.bin")}, exitFailure},
+		{[]string{"test", text}, exitFailure},
+		{[]string{"decompress", "-s", "-o", filepath.Join(dir, "partial"), batch}, exitPartial},
+		{[]string{"decompress", "-s", "-o", filepath.Join(dir, "failed"), bad}, exitFailure},
+	}
+	captureOutput(t)
+	for _, test := range tests {
+		if code := run(context.Background(), test.args); code != test.code {
+			t.Errorf("huffman %s: exit code %d, want %d", strings.Join(test.args, " "), code, test.code)
+		}
+	}
+}
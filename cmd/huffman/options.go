@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// flag set of a command, each option has a short and a long name
+//
+// both -name and --name are accepted for short and long names
+type optionSet struct {
+	flags   *flag.FlagSet
+	usage   string // arguments after command name in usage line
+	summary string
+	options []optionHelp
+}
+
+// help line of an option
+type optionHelp struct {
+	names string // e.g. "-o, --output <path>"
+	usage string
+}
+
+// create a new option set of command name
+func newOptionSet(name, usage, summary string) (ret *optionSet) {
+	ret = new(optionSet)
+	ret.flags = flag.NewFlagSet(name, flag.ContinueOnError)
+	ret.flags.SetOutput(io.Discard)
+	ret.usage = usage
+	ret.summary = summary
+	return ret
+}
+
+// record help of an option
+func (set *optionSet) addHelp(short, long, arg, usage string) {
+	var names []string
+	if short != "" {
+		names = append(names, "-"+short)
+	}
+	if long != "" {
+		names = append(names, "--"+long)
+	}
+	var help optionHelp = optionHelp{names: strings.Join(names, ", "), usage: usage}
+	if arg != "" {
+		help.names += " <" + arg + ">"
+	}
+	set.options = append(set.options, help)
+}
+
+// register fn under short and long names
+func (set *optionSet) funcOption(short, long, arg, usage string, fn func(value string) error) {
+	for _, name := range []string{short, long} {
+		if name != "" {
+			set.flags.Func(name, usage, fn)
+		}
+	}
+	set.addHelp(short, long, arg, usage)
+}
+
+// register a switch
+func (set *optionSet) boolOption(value *bool, short, long, usage string) {
+	for _, name := range []string{short, long} {
+		if name != "" {
+			set.flags.BoolVar(value, name, *value, usage)
+		}
+	}
+	set.addHelp(short, long, "", usage)
+}
+
+// register a string option
+func (set *optionSet) stringOption(value *string, short, long, arg, usage string) {
+	set.funcOption(short, long, arg, usage, func(str string) error {
+		*value = str
+		return nil
+	})
+}
+
+// register an integer option, value must be in [min, max]
+func (set *optionSet) intOption(value *int, short, long, arg, usage string, minValue, maxValue int) {
+	set.funcOption(short, long, arg, usage, func(str string) error {
+		number, err := strconv.Atoi(str)
+		if err != nil || number < minValue || number > maxValue {
+			return fmt.Errorf("invalid value %s, expect integer in [%d, %d]", str, minValue, maxValue)
+		}
+		*value = number
+		return nil
+	})
+}
+
+// register a size option with optional K, M, G suffix, see parseSize
+func (set *optionSet) sizeOption(value *int64, short, long, usage string) {
+	set.funcOption(short, long, "size", usage, func(str string) error {
+		size, err := parseSize(str)
+		if err != nil {
+			return err
+		}
+		*value = size
+		return nil
+	})
+}
+
+// parse arguments, options may come before or after positional arguments
+//
+// return positional arguments, flag.ErrHelp if help is requested
+func (set *optionSet) parse(args []string) (positional []string, err error) {
+	positional = make([]string, 0)
+	for {
+		err = set.flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		var rest []string = set.flags.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		// everything after -- is positional
+		var consumed int = len(args) - len(rest)
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// print usage of command with its options
+func (set *optionSet) printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: huffman %s %s\n\n%s\n", set.flags.Name(), set.usage, set.summary)
+	if len(set.options) == 0 {
+		return
+	}
+
+	// align usage column
+	var width int = 0
+	for _, option := range set.options {
+		width = max(width, len(option.names))
+	}
+	fmt.Fprintf(w, "\nOptions:\n")
+	for _, option := range set.options {
+		fmt.Fprintf(w, "  %-*s  %s\n", width, option.names, option.usage)
+	}
+	fmt.Fprintf(w, "  %-*s  %s\n", width, "-h, --help", "display this help message")
+}
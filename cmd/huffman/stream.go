@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Anslen/huffman"
+)
+
+// input and output of stream mode, "-" for stdin and stdout
+type stream struct {
+	input  *os.File
+	output io.Writer
+	file   *huffman.AtomicFile // output file, nil for stdout
+}
+
+// open input and output of stream mode
+//
+// output file is written to a temporary file, commit moves it to outputPath
+func openStream(inputPath, outputPath string, force bool) (ret *stream, err error) {
+	ret = new(stream)
+	if inputPath == "-" {
+		ret.input = os.Stdin
+	} else {
+		ret.input, err = os.Open(inputPath)
+		if err != nil {
+			return nil, fmt.Errorf("open input file %s failed: %w", inputPath, err)
+		}
+	}
+	if outputPath == "-" {
+		ret.output = os.Stdout
+		return ret, nil
+	}
+
+	// check output before any work
+	outputInfo, err := os.Stat(outputPath)
+	if err == nil && outputInfo.IsDir() {
+		err = fmt.Errorf("output path %s is a directory", outputPath)
+	} else if err == nil && !force {
+		err = fmt.Errorf("%w: %s", huffman.ErrOutputExists, outputPath)
+	} else if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	if err == nil {
+		ret.file, err = huffman.OpenFile(outputPath)
+	}
+	if err != nil {
+		ret.close()
+		return nil, err
+	}
+	ret.output = ret.file
+	return ret, nil
+}
+
+// save output file
+func (s *stream) commit() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Commit()
+}
+
+// close input and output, output file is removed if not committed
+func (s *stream) close() {
+	if s.input != os.Stdin {
+		s.input.Close()
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+}
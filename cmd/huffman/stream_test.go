@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// run command with stdin read from input, return what it writes to stdout
+func runPiped(t *testing.T, input []byte, args ...string) (output []byte, code int) {
+	var dir string = t.TempDir()
+	var inputPath, outputPath string = filepath.Join(dir, "stdin"), filepath.Join(dir, "stdout")
+	if err := os.WriteFile(inputPath, input, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(inputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	stdout, err := os.Create(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+
+	stderr, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+
+	var oldStdin, oldStdout, oldStderr *os.File = os.Stdin, os.Stdout, os.Stderr
+	os.Stdin, os.Stdout, os.Stderr = stdin, stdout, stderr
+	code = run(context.Background(), args)
+	os.Stdin, os.Stdout, os.Stderr = oldStdin, oldStdout, oldStderr
+
+	output, err = os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output, code
+}
+
+// data piped through compress and decompress with - and -c comes back unchanged
+func TestStreamRoundTrip(t *testing.T) {
+	var text []byte = bytes.Repeat([]byte("piped through stdin and stdout\n"), 1000)
+	for _, args := range [][]string{{"-c"}, {"-i", "-", "-o", "-"}} {
+		encoded, code := runPiped(t, text, append([]string{"compress"}, args...)...)
+		if code != exitOK || len(encoded) == 0 || len(encoded) >= len(text) {
+			t.Fatalf("compress %v: exit code %d, %d bytes", args, code, len(encoded))
+		}
+		decoded, code := runPiped(t, encoded, append([]string{"decompress"}, args...)...)
+		if code != exitOK || !bytes.Equal(decoded, text) {
+			t.Fatalf("decompress %v: exit code %d, %d bytes, want %d", args, code, len(decoded), len(text))
+		}
+	}
+
+	// garbage on stdin fails without output
+	output, code := runPiped(t, make([]byte, 99), "decompress", "-c")
+	if code != exitFailure || len(output) != 0 {
+		t.Fatalf("decompress garbage: exit code %d, %d bytes", code, len(output))
+	}
+}
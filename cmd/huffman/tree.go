@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Anslen/huffman"
+)
+
+// options of tree command
+type treeFlags struct {
+	input     string
+	format    string
+	decode    bool
+	maxWidth  int
+	blockSize int64
+	block     int
+}
+
+// register options of tree command
+func (flags *treeFlags) optionSet() (set *optionSet) {
+	set = newOptionSet("tree", "[options] <input>", "Export the huffman tree of a file as Graphviz DOT or nested JSON to stdout.\n"+
+		"By default the tree built from frequency of each byte of the whole input is exported,\n"+
+		"with internal nodes numbered in the order they are created.")
+	set.stringOption(&flags.input, "i", "input", "path", "input file (or pass as argument), - for stdin")
+	set.stringOption(&flags.format, "", "format", "format", "output format, dot (default) or json")
+	set.boolOption(&flags.decode, "", "decode", "export the decode tree of the canonical codes compress uses for the first block instead")
+	set.intOption(&flags.maxWidth, "l", "max-width", "bits", fmt.Sprintf("max huffman code width in bits with --decode (default %d)", huffman.DefaultMaxCodeWidth), 1, huffman.MaxCodeWidth)
+	set.sizeOption(&flags.blockSize, "", "block-size", "size of the first block with --decode, as given to compress, e.g. 64K (default 1M)")
+	set.intOption(&flags.block, "", "block", "n", "input is compressed, export the decode tree of its block n (from 0, see 'huffman info')", 0, 1<<31-1)
+	return set
+}
+
+// option set of tree command for help
+func treeOptions() *optionSet {
+	return new(treeFlags).optionSet()
+}
+
+// node of a huffman tree as JSON
+type jsonTreeValue struct {
+	Symbol    *byte  `json:"symbol,omitempty"`
+	Char      string `json:"char,omitempty"`
+	Index     *int   `json:"index,omitempty"`
+	Frequency *int   `json:"frequency,omitempty"`
+	Code      string `json:"code"`
+}
+
+// tree [options] <input>
+func runTree(ctx context.Context, args []string) int {
+	var flags treeFlags = treeFlags{block: -1}
+	var set *optionSet = flags.optionSet()
+	positional, code, ok := parseOptions(set, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 1 || (len(positional) == 1 && flags.input != "") {
+		return usageError(set, fmt.Errorf("only one input allowed"))
+	}
+	if len(positional) == 1 {
+		flags.input = positional[0]
+	}
+	if flags.input == "" && stdinPiped() {
+		flags.input = "-"
+	}
+	if flags.input == "" {
+		return usageError(set, fmt.Errorf("input file required"))
+	}
+	if flags.format == "" {
+		flags.format = "dot"
+	}
+	if flags.format != "dot" && flags.format != "json" {
+		return usageError(set, fmt.Errorf("unknown format %s, expect dot or json", flags.format))
+	}
+	if flags.block >= 0 && flags.decode {
+		return usageError(set, fmt.Errorf("--block and --decode can not be used together"))
+	}
+	if flags.maxWidth == 0 {
+		flags.maxWidth = huffman.DefaultMaxCodeWidth
+	}
+	if flags.blockSize == 0 {
+		flags.blockSize = huffman.DefaultBlockSize
+	}
+	flags.blockSize = min(flags.blockSize, huffman.MaxBlockSize)
+
+	var input *os.File = os.Stdin
+	if flags.input != "-" {
+		file, err := os.Open(flags.input)
+		if err != nil {
+			return fileError("open input file failed", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	tree, err := buildTree(&flags, input)
+	if err != nil {
+		return fileError(fmt.Sprintf("build huffman tree of %s failed", flags.input), err)
+	}
+	var output *bufio.Writer = bufio.NewWriter(os.Stdout)
+	if flags.format == "json" {
+		err = tree.WriteJSON(output, treeJSONValue)
+	} else {
+		err = tree.WriteDot(output, treeLabel)
+	}
+	if err == nil {
+		err = output.Flush()
+	}
+	if err != nil {
+		return fileError("write huffman tree failed", err)
+	}
+	return exitOK
+}
+
+// build the tree selected by flags from input
+func buildTree(flags *treeFlags, input io.Reader) (tree *huffman.Tree[huffman.HuffmanTreeNode], err error) {
+	// decode tree of a block of compressed input, frequence is unknown
+	if flags.block >= 0 {
+		var info huffman.Info
+		info, err = huffman.ReadInfo(input)
+		if err != nil {
+			return nil, err
+		}
+		if flags.block >= len(info.Blocks) {
+			return nil, fmt.Errorf("block %d out of range, input has %d blocks", flags.block, len(info.Blocks))
+		}
+		return huffman.GetDecodeTree(info.Blocks[flags.block].Codes, nil)
+	}
+
+	// tree of input data, codes are generated for the first block as compress does
+	var data []byte
+	if flags.decode {
+		input = io.LimitReader(input, flags.blockSize)
+	}
+	data, err = io.ReadAll(input)
+	if err != nil {
+		return nil, fmt.Errorf("read input failed: %w", err)
+	}
+	var frequence map[byte]int = make(map[byte]int)
+	for _, char := range data {
+		frequence[char]++
+	}
+	if !flags.decode {
+		return huffman.GetFrequenceTree(frequence), nil
+	}
+	var codes huffman.HuffmanCodes
+	codes, err = huffman.GetLimitedHuffmanCodes(string(data), flags.maxWidth)
+	if err != nil {
+		return nil, err
+	}
+	return huffman.GetDecodeTree(codes, frequence)
+}
+
+// label of a node in DOT: symbol, frequency and code of leaves,
+// index and frequency of internal nodes, unknown values are left out
+func treeLabel(node *huffman.Tree[huffman.HuffmanTreeNode]) string {
+	var value huffman.HuffmanTreeNode = node.Value
+	var lines []string
+	if value.Leaf {
+		lines = append(lines, symbolName(value.Char))
+	}
+	if value.Index >= 0 {
+		lines = append(lines, fmt.Sprintf("#%d", value.Index))
+	}
+	if value.Frequence >= 0 {
+		lines = append(lines, fmt.Sprintf("freq %d", value.Frequence))
+	}
+	if value.Leaf {
+		lines = append(lines, treeCode(value.Code))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// value of a node in JSON, see jsonTreeValue
+func treeJSONValue(node *huffman.Tree[huffman.HuffmanTreeNode]) any {
+	var value huffman.HuffmanTreeNode = node.Value
+	var ret jsonTreeValue = jsonTreeValue{Code: treeCode(value.Code)}
+	if value.Leaf {
+		ret.Symbol = &value.Char
+		ret.Char = symbolName(value.Char)
+	}
+	if value.Index >= 0 {
+		ret.Index = &value.Index
+	}
+	if value.Frequence >= 0 {
+		ret.Frequency = &value.Frequence
+	}
+	return ret
+}
+
+// code of a node, empty for the root
+func treeCode(code huffman.HuffmanCode) string {
+	if code.Width == 0 {
+		return ""
+	}
+	return codeString(code)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// trees of input holding every byte value export each symbol once, escaped in DOT
+func TestTreeAllBytes(t *testing.T) {
+	var input []byte
+	for char := 0; char < 256; char++ {
+		input = append(input, bytes.Repeat([]byte{byte(char)}, 1+char%7)...)
+	}
+
+	// leaf labels start with the symbol, the only escapes are \\, \" and \n
+	output, code := runPiped(t, input, "tree", "-")
+	if code != exitOK {
+		t.Fatalf("tree: exit code %d", code)
+	}
+	var leafLine = regexp.MustCompile(`^\tn\d+ \[label="((?:[^"\\\x00-\x1f\x7f]|\\[\\"n])*)", shape=box\];$`)
+	var symbols map[string]bool = make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.Contains(line, "shape=box") {
+			continue
+		}
+		var match []string = leafLine.FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("invalid leaf %q", line)
+		}
+		var label string = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n").Replace(match[1])
+		symbols[strings.SplitN(label, "\n", 2)[0]] = true
+	}
+	for char := 0; char < 256; char++ {
+		if !symbols[symbolName(byte(char))] {
+			t.Errorf("symbol %s not found", symbolName(byte(char)))
+		}
+	}
+
+	// JSON holds the same leaves
+	output, code = runPiped(t, input, "tree", "--format", "json", "-")
+	if code != exitOK {
+		t.Fatalf("tree --format json: exit code %d", code)
+	}
+	type node struct {
+		Value jsonTreeValue `json:"value"`
+		Left  *node         `json:"left"`
+		Right *node         `json:"right"`
+	}
+	var root node
+	if err := json.Unmarshal(output, &root); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	var seen [256]bool
+	var walk func(tree *node)
+	walk = func(tree *node) {
+		if tree == nil {
+			return
+		}
+		if symbol := tree.Value.Symbol; symbol != nil {
+			if seen[*symbol] || tree.Value.Char != symbolName(*symbol) {
+				t.Errorf("leaf %d named %s, seen before %v", *symbol, tree.Value.Char, seen[*symbol])
+			}
+			seen[*symbol] = true
+		}
+		walk(tree.Left)
+		walk(tree.Right)
+	}
+	walk(&root)
+	for char, ok := range seen {
+		if !ok {
+			t.Errorf("symbol %d not found", char)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Anslen/huffman"
+)
+
+// options of test command
+type testFlags struct {
+	input       string
+	silent      bool
+	json        bool
+	jobs        int
+	batchMemory int64
+	maxOutput   int64
+	maxTable    int64
+	maxMemory   int64
+}
+
+// register options of test command
+func (flags *testFlags) optionSet() (set *optionSet) {
+	set = newOptionSet("test", "[options] <input>", "Decode a compressed file, or all files in a directory, without writing output,\n"+
+		"and report whether each file passes with its tables, sizes and checksum.")
+	set.stringOption(&flags.input, "i", "input", "path", "input file or directory (or pass as argument), - for stdin")
+	set.boolOption(&flags.silent, "s", "silent", "only print failed files and the summary")
+	set.boolOption(&flags.json, "", "json", "print results as JSON, one object per line for a directory, see 'huffman help json'")
+	set.intOption(&flags.jobs, "j", "jobs", "n", "number of files tested at the same time (default number of CPUs)", 1, 1<<16)
+	set.sizeOption(&flags.batchMemory, "", "batch-memory", "memory budget of files tested at the same time, e.g. 1G (default no limit)")
+	set.sizeOption(&flags.maxOutput, "", "max-output", "max decoded size of each file, e.g. 512M (default no limit)")
+	set.sizeOption(&flags.maxTable, "", "max-table", "max huffman table size, e.g. 1K (default no limit)")
+	set.sizeOption(&flags.maxMemory, "", "max-memory", "max memory of a block, e.g. 64M (default no limit)")
+	return set
+}
+
+// option set of test command for help
+func testOptions() *optionSet {
+	return new(testFlags).optionSet()
+}
+
+// test [options] <input>
+func runTest(ctx context.Context, args []string) int {
+	var flags testFlags
+	var set *optionSet = flags.optionSet()
+	positional, code, ok := parseOptions(set, args)
+	if !ok {
+		return code
+	}
+	if len(positional) > 1 || (len(positional) == 1 && flags.input != "") {
+		return usageError(set, fmt.Errorf("only one input allowed"))
+	}
+	if len(positional) == 1 {
+		flags.input = positional[0]
+	}
+	if flags.input == "" && stdinPiped() {
+		flags.input = "-"
+	}
+	if flags.input == "" {
+		return usageError(set, fmt.Errorf("input file required"))
+	}
+	if flags.input != "-" {
+		flags.input = filepath.Clean(flags.input)
+	}
+
+	var options huffman.DecodeOptions
+	options.MaxOutputSize = flags.maxOutput
+	options.MaxTableSize = int(min(flags.maxTable, 1<<31-1))
+	options.MaxMemory = flags.maxMemory
+	options.Jobs = flags.jobs
+	options.BatchMemory = flags.batchMemory
+
+	if info, err := os.Stat(flags.input); err == nil && info.IsDir() {
+		return testBatch(ctx, &flags, &options)
+	}
+	return testFile(ctx, &flags, &options)
+}
+
+// test all files in input directory
+func testBatch(ctx context.Context, flags *testFlags, options *huffman.DecodeOptions) int {
+	var report huffman.ProgressFunc = jsonProgress(os.Stdout, "test")
+	if !flags.json {
+		report = func(event huffman.ProgressEvent) {
+			if event.Type == huffman.ProgressFileFinished {
+				printTestResult(event.Path, event.DecodeSize, event.Err, flags.silent)
+			}
+		}
+	}
+	// events are serialized by BatchVerifyContext
+	var decoded int64
+	options.Progress = func(event huffman.ProgressEvent) {
+		if event.Type == huffman.ProgressFileFinished && event.Err == nil {
+			decoded += int64(event.DecodeSize.Decoded)
+		}
+		report(event)
+	}
+	result, err := huffman.BatchVerifyContext(ctx, flags.input, options)
+	if err != nil && result.InputPath == "" {
+		if flags.json {
+			writeJSON(os.Stdout, decodeResult("test", "result", flags.input, "", huffman.DecodeSize{}, 0, err))
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: batch testing failed:\n%v\n", err)
+		}
+		return exitFailure
+	}
+
+	if flags.json {
+		writeJSON(os.Stdout, jsonSummary{
+			Type:         "summary",
+			Command:      "test",
+			Input:        result.InputPath,
+			Total:        result.TotalCount,
+			Successful:   result.SuccessCount,
+			Failed:       len(result.Errors),
+			OriginalSize: decoded,
+			Seconds:      result.Time.Seconds(),
+		})
+	} else {
+		fmt.Printf("\nBatch testing completed.\n")
+		fmt.Printf("Input path: %s\n", result.InputPath)
+		fmt.Printf("Total files: %d\n", result.TotalCount)
+		fmt.Printf("Passed: %d\n", result.SuccessCount)
+		fmt.Printf("Failed: %d\n", len(result.Errors))
+		fmt.Printf("Time taken: %.2fs\n", float64(result.Time.Milliseconds())/1000)
+	}
+	if err != nil {
+		return exitFailure
+	}
+	return batchExitCode(result.SuccessCount, len(result.Errors))
+}
+
+// test a single file, - for stdin
+func testFile(ctx context.Context, flags *testFlags, options *huffman.DecodeOptions) int {
+	var startTime time.Time = time.Now()
+	var decodeSize huffman.DecodeSize
+	var err error
+	if flags.input == "-" {
+		decodeSize, _, err = huffman.DecodeStream(ctx, os.Stdin, io.Discard, options)
+	} else {
+		decodeSize, _, err = huffman.VerifyContext(ctx, flags.input, options)
+	}
+
+	if flags.json {
+		writeJSON(os.Stdout, decodeResult("test", "result", flags.input, "", decodeSize, time.Since(startTime), err))
+	} else {
+		printTestResult(flags.input, decodeSize, err, flags.silent)
+	}
+	if err != nil {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// print pass or failure of a tested file
+func printTestResult(path string, decodeSize huffman.DecodeSize, err error, silent bool) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FAILED: %s:\n%v\n", path, err)
+		return
+	}
+	if silent {
+		return
+	}
+	var checksum string = "no checksum"
+	if decodeSize.Checksum.Algorithm != huffman.ChecksumNone {
+		checksum = fmt.Sprintf("checksum %v verified", decodeSize.Checksum)
+	}
+	fmt.Printf("OK: %s (%d bytes, %s)\n", path, decodeSize.Decoded, checksum)
+}
@@ -1,16 +1,21 @@
-package main
+package huffman
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sync"
 	"time"
 )
 
 type DecodeSize struct {
 	Original int // in bytes
 	Decoded  int // in bytes
+	Checksum Checksum
 }
 
 type BatchDecodeResult struct {
@@ -20,59 +25,242 @@ type BatchDecodeResult struct {
 	SuccessCount int
 	Time         time.Duration
 	Errors       []BatchError
+	Skipped      []BatchError // files whose output already exists
 }
 
+// decode input file to output file
+//
+// if outptuPath is an existing directory, output file is placed in it, see DecodedPath
+//
+// mode and times stored in the header are restored to output file
 func Decode(inputPath, outptuPath string) (decodeSize DecodeSize, decodeTime time.Duration, err error) {
+	return DecodeWithOptions(inputPath, outptuPath, nil)
+}
+
+// get path of the file Decode writes
+//
+// if outputPath is an existing directory, return the name stored in the header of
+// input file in the directory, or input name without extension if no name is stored
+// or options.IgnoreName is set
+func DecodedPath(inputPath, outputPath string, options *DecodeOptions) (path string, err error) {
+	outputInfo, err := os.Stat(outputPath)
+	if err != nil || !outputInfo.IsDir() {
+		return outputPath, nil
+	}
+	var header Header
+	header, err = readFileHeader(inputPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(outputPath, decodedName(inputPath, header, options != nil && options.IgnoreName)), nil
+}
+
+// read header of encoded file
+func readFileHeader(path string) (header Header, err error) {
+	var file *os.File
+	file, err = os.Open(path)
+	if err != nil {
+		return header, fmt.Errorf("open input file %s failed:\n%w", path, err)
+	}
+	defer file.Close()
+	header, err = NewReader(file).Header()
+	if err != nil {
+		return header, fmt.Errorf("read header of %s failed:\n%w", path, err)
+	}
+	return header, nil
+}
+
+// same as Decode, options may be nil for no limit
+func DecodeWithOptions(inputPath, outptuPath string, options *DecodeOptions) (decodeSize DecodeSize, decodeTime time.Duration, err error) {
+	return DecodeContext(context.Background(), inputPath, outptuPath, options)
+}
+
+// same as DecodeWithOptions, stop when ctx is done
+//
+// output file is not created if decoding fails or is canceled
+func DecodeContext(ctx context.Context, inputPath, outptuPath string, options *DecodeOptions) (decodeSize DecodeSize, decodeTime time.Duration, err error) {
 	// record start time
 	var startTime time.Time = time.Now()
+	if options == nil {
+		options = &DecodeOptions{}
+	}
+	var event ProgressEvent = ProgressEvent{Path: inputPath, Total: -1}
+	defer func() {
+		event.Type = ProgressFileFinished
+		event.Err = err
+		event.Time = time.Since(startTime)
+		event.DecodeSize = decodeSize
+		options.Progress.report(event)
+	}()
+	err = ctx.Err()
+	if err != nil {
+		return decodeSize, decodeTime, err
+	}
 
-	// read input file
-	var bytes []byte
-	bytes, err = os.ReadFile(inputPath)
+	// open input file
+	var inputFile *os.File
+	inputFile, err = os.Open(inputPath)
 	if err != nil {
-		return decodeSize, decodeTime, fmt.Errorf("open input file %s failed:\n%v", inputPath, err.Error())
+		return decodeSize, decodeTime, fmt.Errorf("open input file %s failed:\n%w", inputPath, err)
 	}
-	var reader *BitsReader = NewBitsReader(bytes, len(bytes)*8)
+	defer inputFile.Close()
+	var inputInfo os.FileInfo
+	inputInfo, err = inputFile.Stat()
+	if err != nil {
+		return decodeSize, decodeTime, fmt.Errorf("stat input file %s failed:\n%w", inputPath, err)
+	}
+	event.Total = inputInfo.Size()
 
-	// read huffman table
-	var codes HuffmanCodes
-	codes, err = readHuffmanTable(reader)
+	// check header before creating output, bytes are reported once the file is started
+	var input *progressReader = newProgressReader(ctx, inputFile, nil, event)
+	var reader *Reader = NewReaderWithOptions(input, options)
+	var header Header
+	header, err = reader.Header()
 	if err != nil {
-		return decodeSize, decodeTime, fmt.Errorf("read huffman table failed:\n%v", err.Error())
+		return decodeSize, decodeTime, fmt.Errorf("read header of %s failed:\n%w", inputPath, err)
 	}
 
-	// build huffman tree and read string
-	var tree *Tree[byte] = GetHuffmanTree(codes)
-	var text []byte
-	text, err = readString(reader, tree)
+	// check output before any work
+	if outputInfo, statErr := os.Stat(outptuPath); statErr == nil && outputInfo.IsDir() {
+		outptuPath = filepath.Join(outptuPath, decodedName(inputPath, header, options.IgnoreName))
+	}
+	event.OutputPath = outptuPath
+	input.event.OutputPath = outptuPath
+	err = checkOutputPath(inputPath, outptuPath, options.Force)
+	if err != nil {
+		return decodeSize, decodeTime, err
+	}
+	// input is only removed when decoded data is verified
+	if options.RemoveInput && header.Checksum == ChecksumNone {
+		return decodeSize, decodeTime, fmt.Errorf("%w: %s can not be removed after decoding", ErrNoChecksum, inputPath)
+	}
+	event.Type = ProgressFileStarted
+	options.Progress.report(event)
+	input.progress = options.Progress
 
 	// open output file
-	var outputFile *os.File
+	var outputFile *AtomicFile
 	outputFile, err = OpenFile(outptuPath)
 	if err != nil {
-		return decodeSize, decodeTime, fmt.Errorf("open output file %s failed:\n%v", outptuPath, err.Error())
+		return decodeSize, decodeTime, fmt.Errorf("open output file %s failed:\n%w", outptuPath, err)
 	}
 	defer outputFile.Close()
 
-	// write text to output file
-	_, err = outputFile.Write(text)
+	// decode input to output
+	var buffered *bufio.Writer = bufio.NewWriter(outputFile)
+	var decoded int64
+	decoded, err = io.Copy(buffered, reader)
+	event.Bytes = input.event.Bytes
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return decodeSize, decodeTime, fmt.Errorf("decode file %s canceled:\n%w", inputPath, ctxErr)
+	}
+	if err != nil {
+		return decodeSize, decodeTime, fmt.Errorf("decode file %s failed:\n%w", inputPath, err)
+	}
+	err = buffered.Flush()
+	if err != nil {
+		return decodeSize, decodeTime, fmt.Errorf("write decoded data to file %s failed:\n%w", outptuPath, err)
+	}
+	err = restoreFileMetadata(outputFile.File, header, options.SpecialBits)
+	if err != nil {
+		return decodeSize, decodeTime, fmt.Errorf("restore attributes of %s failed:\n%w", outptuPath, err)
+	}
+	err = outputFile.Commit()
+	if err != nil {
+		return decodeSize, decodeTime, fmt.Errorf("save output file %s failed:\n%w", outptuPath, err)
+	}
+
+	// decoded data is verified by checksum, remove input
+	if options.RemoveInput {
+		inputFile.Close()
+		err = os.Remove(inputPath)
+		if err != nil {
+			return decodeSize, decodeTime, fmt.Errorf("remove input file %s failed:\n%w", inputPath, err)
+		}
+	}
+
+	// record size and time
+	decodeSize = DecodeSize{
+		Original: int(inputInfo.Size()),
+		Decoded:  int(decoded),
+		Checksum: reader.Checksum(),
+	}
+	decodeTime = time.Since(startTime)
+	return decodeSize, decodeTime, nil
+}
+
+// decode data read from r and write it to w, stop when ctx is done
+//
+// r and w need not be seekable, decoded data is written block by block, so
+// w may have received data when the checksum turns out to be wrong
+//
+// only limits of options are used, metadata in the header is not restored
+func DecodeStream(ctx context.Context, r io.Reader, w io.Writer, options *DecodeOptions) (decodeSize DecodeSize, decodeTime time.Duration, err error) {
+	// record start time
+	var startTime time.Time = time.Now()
+	err = ctx.Err()
+	if err != nil {
+		return decodeSize, decodeTime, err
+	}
+
+	var input *progressReader = newProgressReader(ctx, r, nil, ProgressEvent{})
+	var reader *Reader = NewReaderWithOptions(input, options)
+	_, err = reader.Header()
+	if err != nil {
+		return decodeSize, decodeTime, fmt.Errorf("read header failed:\n%w", err)
+	}
+
+	// decode input to output
+	var buffered *bufio.Writer = bufio.NewWriter(w)
+	var decoded int64
+	decoded, err = io.Copy(buffered, reader)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return decodeSize, decodeTime, fmt.Errorf("decode stream canceled:\n%w", ctxErr)
+	}
+	if err != nil {
+		return decodeSize, decodeTime, fmt.Errorf("decode stream failed:\n%w", err)
+	}
+	err = buffered.Flush()
 	if err != nil {
-		return decodeSize, decodeTime, fmt.Errorf("write decoded data to file %s failed:\n%v", outptuPath, err.Error())
+		return decodeSize, decodeTime, fmt.Errorf("write decoded data failed:\n%w", err)
 	}
 
 	// record size and time
 	decodeSize = DecodeSize{
-		Original: len(bytes),
-		Decoded:  len(text),
+		Original: int(input.event.Bytes),
+		Decoded:  int(decoded),
+		Checksum: reader.Checksum(),
 	}
 	decodeTime = time.Since(startTime)
 	return decodeSize, decodeTime, nil
 }
 
+// decode bytes produced by Encode or EncodeBytes in memory
+func DecodeBytes(data []byte) (text []byte, err error) {
+	return io.ReadAll(NewReader(bytes.NewReader(data)))
+}
+
 func BatchDecode(inputPath string, outputPath string) (result BatchDecodeResult, err error) {
+	return BatchDecodeWithOptions(inputPath, outputPath, nil)
+}
+
+// same as BatchDecode, options are used for each file
+func BatchDecodeWithOptions(inputPath string, outputPath string, options *DecodeOptions) (result BatchDecodeResult, err error) {
+	return BatchDecodeContext(context.Background(), inputPath, outputPath, options)
+}
+
+// same as BatchDecodeWithOptions, stop when ctx is done
+//
+// files not finished when ctx is done are reported as errors, and ctx.Err() is returned with the result
+func BatchDecodeContext(ctx context.Context, inputPath string, outputPath string, options *DecodeOptions) (result BatchDecodeResult, err error) {
 	// record start time
 	var startTime time.Time = time.Now()
 	var errors []BatchError = make([]BatchError, 0)
+	var fileOptions DecodeOptions
+	if options != nil {
+		fileOptions = *options
+	}
+	fileOptions.Progress = fileOptions.Progress.serialized()
 
 	// normalize input & output paths
 	inputPath = filepath.Clean(inputPath)
@@ -83,99 +271,214 @@ func BatchDecode(inputPath string, outputPath string) (result BatchDecodeResult,
 	var getFilesErrors []BatchError
 	inputFiles, getFilesErrors, err = GetFilesInDir(inputPath)
 	if err != nil {
-		return result, fmt.Errorf("get input files failed: %v", err.Error())
+		return result, fmt.Errorf("get input files failed: %w", err)
 	}
 	errors = append(errors, getFilesErrors...)
 
-	// get output paths for input files
+	// get output paths for input files from their headers
 	var outputPaths []string
 	var getOutputPathErrors []BatchError
-	outputPaths, getOutputPathErrors = GetOutputPaths(inputFiles, outputPath, "txt")
+	var totalCount int = len(inputFiles)
+	inputFiles, outputPaths, getOutputPathErrors = getDecodedPaths(inputPath, inputFiles, outputPath, &fileOptions)
 	errors = append(errors, getOutputPathErrors...)
+	reportBatchErrors(fileOptions.Progress, errors)
 
-	// process each file with goroutines
+	// process files with a bounded number of goroutines
+	var decodeErrors []error = make([]error, len(inputFiles))
+	runBatch(ctx, len(inputFiles), fileOptions.Jobs, fileOptions.BatchMemory, func(idx int) int64 {
+		return decodeMemory(inputFiles[idx])
+	}, func(idx int) {
+		_, _, decodeErrors[idx] = DecodeContext(ctx, inputFiles[idx], outputPaths[idx], &fileOptions)
+	})
+
+	// collect results in input order
 	var success int = 0
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	for i, inputPath := range inputFiles {
-		wg.Add(1)
-		go func(idx int, inPath string) {
-			defer wg.Done()
-			// decode
-			_, _, decErr := Decode(inPath, outputPaths[idx])
-			mu.Lock()
-			defer mu.Unlock()
-			if decErr != nil {
-				errors = append(errors, BatchError{Path: inPath, Err: decErr})
-			} else {
-				success++
-			}
-		}(i, inputPath)
+	var skipped []BatchError = make([]BatchError, 0)
+	for idx, decErr := range decodeErrors {
+		if isSkipped(decErr) {
+			skipped = append(skipped, BatchError{Path: inputFiles[idx], Err: decErr})
+		} else if decErr != nil {
+			errors = append(errors, BatchError{Path: inputFiles[idx], Err: decErr})
+		} else {
+			success++
+		}
 	}
-
-	wg.Wait()
+	sortBatchErrors(errors)
 
 	// fill result
 	result = BatchDecodeResult{
 		InputPath:    inputPath,
 		OutputPath:   outputPath,
-		TotalCount:   len(inputFiles),
+		TotalCount:   totalCount,
 		SuccessCount: success,
 		Time:         time.Since(startTime),
 		Errors:       errors,
+		Skipped:      skipped,
+	}
+	return result, ctx.Err()
+}
+
+// get output paths of decoded files
+//
+// output files mirror the directory structure of input files under inputDir,
+// named by the names stored in their headers, see DecodedPath
+//
+// files whose header can not be read or with the same output path as another
+// file are reported as errors and not returned in validPaths
+func getDecodedPaths(inputDir string, inputPaths []string, outputDir string, options *DecodeOptions) (validPaths []string, outputPaths []string, errors []BatchError) {
+	validPaths = make([]string, 0, len(inputPaths))
+	outputPaths = make([]string, 0, len(inputPaths))
+	for _, inputPath := range inputPaths {
+		relPath, err := relativePath(inputDir, inputPath)
+		if err != nil {
+			errors = append(errors, BatchError{Path: inputPath, Err: err})
+			continue
+		}
+		header, err := readFileHeader(inputPath)
+		if err != nil {
+			errors = append(errors, BatchError{Path: inputPath, Err: err})
+			continue
+		}
+		var name string = decodedName(inputPath, header, options != nil && options.IgnoreName)
+		validPaths = append(validPaths, inputPath)
+		outputPaths = append(outputPaths, filepath.Join(outputDir, filepath.Dir(relPath), name))
 	}
-	return result, nil
+
+	var collisionErrors []BatchError
+	validPaths, outputPaths, collisionErrors = removeCollisions(validPaths, outputPaths)
+	return validPaths, outputPaths, append(errors, collisionErrors...)
 }
 
 // read huffman table from reader
+//
+// return canonical codes rebuilt from code widths, see writeHuffmanTable for the format
 func readHuffmanTable(reader *BitsReader) (codes HuffmanCodes, err error) {
 	codes = make(HuffmanCodes)
-	var codeWidth uint8
-	var char byte
-	var code uint64
 
-	for {
-		// ok flag for read
-		var codeWidthOk, charOk, codeOk bool
+	// read table type
+	tableType, ok := reader.GetUint8()
+	if !ok {
+		return nil, fmt.Errorf("%w: truncated table", ErrCorruptTable)
+	}
 
-		// read width, check read fail
-		codeWidth, codeWidthOk = reader.GetUint8()
-		if !codeWidthOk {
-			return nil, fmt.Errorf("failed to read huffman table")
+	switch tableType {
+	case tableEmpty:
+		return codes, nil
+
+	case tableList:
+		count, ok := reader.GetUint8()
+		if !ok {
+			return nil, fmt.Errorf("%w: truncated table", ErrCorruptTable)
 		}
-		// check end of huffman table
-		if codeWidth == 0 {
-			break
+		for i := 0; i <= int(count); i++ {
+			char, charOk := reader.GetByte()
+			codeWidth, codeWidthOk := reader.GetUint8()
+			if !charOk || !codeWidthOk {
+				return nil, fmt.Errorf("%w: truncated table", ErrCorruptTable)
+			}
+			if _, ok := codes[char]; ok {
+				return nil, fmt.Errorf("%w: duplicate character %d", ErrCorruptTable, char)
+			}
+			codes[char] = HuffmanCode{Width: codeWidth}
 		}
 
-		// read char
-		char, charOk = reader.GetByte()
+	case tableBitmap:
+		var chars []byte = make([]byte, 0, 256)
+		for char := 0; char < 256; char++ {
+			bit, ok := reader.GetBit()
+			if !ok {
+				return nil, fmt.Errorf("%w: truncated table", ErrCorruptTable)
+			}
+			if bit == 1 {
+				chars = append(chars, byte(char))
+			}
+		}
+		for _, char := range chars {
+			codeWidth, ok := reader.GetUint8()
+			if !ok {
+				return nil, fmt.Errorf("%w: truncated table", ErrCorruptTable)
+			}
+			codes[char] = HuffmanCode{Width: codeWidth}
+		}
 
-		// read code
-		// calculate and skip invalid zeros
-		var codeStoreWidth = (codeWidth + 7) / 8 * 8
-		var offset = codeStoreWidth - codeWidth
-		reader.Seek(int(offset))
-		code, codeOk = reader.GetNBits(int(codeWidth))
+	default:
+		return nil, fmt.Errorf("%w: unknown table type %d", ErrCorruptTable, tableType)
+	}
 
-		if !charOk || !codeOk {
-			return nil, fmt.Errorf("failed to read huffman table")
+	// check code widths, canonical codes are prefix-free when Kraft sum is 1
+	err = checkCodeWidths(codes)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalCodes(codes), nil
+}
+
+// max number of codes in a legacy huffman table
+const legacyMaxCodes = 256
+
+// read huffman table of legacy files, see LegacyVersion
+//
+// codes are stored as is and need not be canonical
+//
+// format:
+//
+//	n group of:
+//	    1 byte   : code width (in bits)
+//	    1 byte   : character
+//	    m bytes  : code, low bits valid, MSB first (m = code width / 8, rounded up)
+//	1 byte   : 0 (end of table)
+func readLegacyHuffmanTable(reader *BitsReader) (codes HuffmanCodes, err error) {
+	codes = make(HuffmanCodes)
+	for {
+		codeWidth, ok := reader.GetUint8()
+		if !ok {
+			return nil, fmt.Errorf("%w: truncated table", ErrCorruptTable)
+		}
+		// end of table
+		if codeWidth == 0 {
+			break
+		}
+		if codeWidth > MaxCodeWidth {
+			return nil, fmt.Errorf("%w: invalid code width %d", ErrCorruptTable, codeWidth)
+		}
+		if len(codes) == legacyMaxCodes {
+			return nil, fmt.Errorf("%w: more than %d codes", ErrCorruptTable, legacyMaxCodes)
 		}
 
-		// save code
+		// skip padding before low bits of code
+		char, charOk := reader.GetByte()
+		var storeWidth int = (int(codeWidth) + 7) / 8 * 8
+		reader.Seek(storeWidth - int(codeWidth))
+		code, codeOk := reader.GetNBits(int(codeWidth))
+		if !charOk || !codeOk {
+			return nil, fmt.Errorf("%w: truncated table", ErrCorruptTable)
+		}
+		if _, ok := codes[char]; ok {
+			return nil, fmt.Errorf("%w: duplicate character %d", ErrCorruptTable, char)
+		}
 		codes[char] = HuffmanCode{Code: code, Width: codeWidth}
 	}
+
+	// prefix conflicts are found when building the lookup table or tree
+	err = checkCodeWidths(codes)
+	if err != nil {
+		return nil, err
+	}
 	return codes, nil
 }
 
+// decoded text is longer than allowed
+var errTooLong = errors.New("decoded text too long")
+
 // read string from reader
-func readString(reader *BitsReader, tree *Tree[byte]) (text []byte, err error) {
+//
+// return errTooLong if more than maxLength bytes are decoded, maxLength -1 for no limit
+func readString(reader *BitsReader, tree *Tree[byte], maxLength int64) (text []byte, err error) {
 	// read data width
 	var dataWidth uint64
 	dataWidth, ok := reader.GetUint64()
 	if !ok {
-		return nil, fmt.Errorf("failed to read data width")
+		return nil, fmt.Errorf("%w: failed to read data width", ErrTruncatedData)
 	}
 
 	var currentNode *Tree[byte] = tree
@@ -184,7 +487,7 @@ func readString(reader *BitsReader, tree *Tree[byte]) (text []byte, err error) {
 		var bit uint8
 		bit, ok = reader.GetBit()
 		if !ok {
-			return nil, fmt.Errorf("failed to read data:\nno enough bits")
+			return nil, fmt.Errorf("%w: no enough bits", ErrTruncatedData)
 		}
 
 		// move acrodding to bit
@@ -196,11 +499,14 @@ func readString(reader *BitsReader, tree *Tree[byte]) (text []byte, err error) {
 
 		// validate currentNode before accessing children
 		if currentNode == nil {
-			return nil, fmt.Errorf("invalid encoding data: reached nil node")
+			return nil, fmt.Errorf("%w: reached nil node", ErrInvalidCode)
 		}
 
 		// add data to ret when reach leaf node
 		if currentNode.Left == nil && currentNode.Right == nil {
+			if int64(len(text)) == maxLength {
+				return nil, errTooLong
+			}
 			text = append(text, currentNode.Value)
 			currentNode = tree
 		}
@@ -208,7 +514,7 @@ func readString(reader *BitsReader, tree *Tree[byte]) (text []byte, err error) {
 
 	// check reach end
 	if currentNode != tree {
-		return nil, fmt.Errorf("invalid encoding data")
+		return nil, fmt.Errorf("%w: data ends inside a code", ErrInvalidCode)
 	}
 	return text, nil
 }
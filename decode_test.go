@@ -0,0 +1,329 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// size of benchmark input (in bytes)
+const benchmarkSize = 4 << 20
+
+// generate text with skewed character distribution
+func benchmarkText(size int) []byte {
+	var random *rand.Rand = rand.New(rand.NewSource(1))
+	var text []byte = make([]byte, size)
+	for i := range text {
+		text[i] = byte(random.ExpFloat64() * 16)
+	}
+	return text
+}
+
+// encode text as a single block, return the block and its huffman table
+func benchmarkBlock(b *testing.B) (text []byte, block []byte, codes HuffmanCodes) {
+	text = benchmarkText(benchmarkSize)
+	var buffer bytes.Buffer
+	var writer *Writer = NewWriter(&buffer, &EncodeOptions{BlockSize: len(text)})
+	writer.Write(text)
+	if err := writer.Close(); err != nil {
+		b.Fatal(err)
+	}
+	// skip stream header and block header
+	block = buffer.Bytes()[headerSize+blockHeaderSize:]
+
+	codes, err := readHuffmanTable(NewBitsReader(block, len(block)*8))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return text, block, codes
+}
+
+func BenchmarkReadString(b *testing.B) {
+	text, block, codes := benchmarkBlock(b)
+	tree, err := GetHuffmanTree(codes)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var reader *BitsReader = NewBitsReader(block, len(block)*8)
+		readHuffmanTable(reader)
+		decoded, err := readString(reader, tree, -1)
+		if err != nil || !bytes.Equal(decoded, text) {
+			b.Fatal("decoded text mismatch", err)
+		}
+	}
+}
+
+func BenchmarkReadStringWithTable(b *testing.B) {
+	text, block, codes := benchmarkBlock(b)
+	table, err := newLookupTable(codes)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var reader *BitsReader = NewBitsReader(block, len(block)*8)
+		readHuffmanTable(reader)
+		decoded, err := readStringWithTable(reader, table, -1)
+		if err != nil || !bytes.Equal(decoded, text) {
+			b.Fatal("decoded text mismatch", err)
+		}
+	}
+}
+
+func BenchmarkDecodeBytes(b *testing.B) {
+	var text []byte = benchmarkText(benchmarkSize)
+	encoded, _, err := EncodeBytes(text)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		decoded, err := DecodeBytes(encoded)
+		if err != nil || !bytes.Equal(decoded, text) {
+			b.Fatal("decoded text mismatch", err)
+		}
+	}
+}
+
+// files encoded by the release before canonical codes, with their original data
+var legacyFixtures = []string{"text", "single", "skewed"}
+
+// read a legacy fixture, return encoded and original data
+func readLegacyFixture(t *testing.T, name string) (encoded []byte, original []byte) {
+	encoded, err := os.ReadFile(filepath.Join("testdata", "legacy", name+".bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err = os.ReadFile(filepath.Join("testdata", "legacy", name+".txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded, original
+}
+
+// legacy blocks decode with both the tree walker and the lookup table
+func TestReadLegacyBlock(t *testing.T) {
+	for _, name := range legacyFixtures {
+		encoded, original := readLegacyFixture(t, name)
+		block, err := readLegacyBlock(bufio.NewReader(bytes.NewReader(encoded)), nil, nil)
+		if err != nil {
+			t.Fatalf("%s: read block failed: %v", name, err)
+		}
+		if len(block) != len(encoded) {
+			t.Fatalf("%s: block has %d bytes, file has %d bytes", name, len(block), len(encoded))
+		}
+
+		reader := NewBitsReader(block, len(block)*8)
+		codes, err := readLegacyHuffmanTable(reader)
+		if err != nil {
+			t.Fatalf("%s: read table failed: %v", name, err)
+		}
+		var data BitsReader = *reader
+		tree, err := GetHuffmanTree(codes)
+		if err != nil {
+			t.Fatalf("%s: build tree failed: %v", name, err)
+		}
+		text, err := readString(reader, tree, -1)
+		if err != nil || !bytes.Equal(text, original) {
+			t.Fatalf("%s: tree walker decoded %q, %v", name, text, err)
+		}
+		table, err := newLookupTable(codes)
+		if err != nil {
+			t.Fatalf("%s: build lookup table failed: %v", name, err)
+		}
+		text, err = readStringWithTable(&data, table, -1)
+		if err != nil || !bytes.Equal(text, original) {
+			t.Fatalf("%s: lookup table decoded %q, %v", name, text, err)
+		}
+	}
+}
+
+// corrupt legacy tables are rejected as ErrCorruptTable
+func TestReadLegacyHuffmanTableCorrupt(t *testing.T) {
+	var tests = map[string][]byte{
+		"truncated":       {2, 'a'},
+		"width 65":        append([]byte{65, 'a'}, make([]byte, 9)...),
+		"duplicate":       {1, 'a', 0, 1, 'a', 1, 0},
+		"incomplete":      {2, 'a', 0, 1, 'b', 1, 0},
+		"kraft sum above": {1, 'a', 0, 1, 'b', 1, 1, 'c', 1, 0},
+	}
+	for name, table := range tests {
+		_, err := readLegacyHuffmanTable(NewBitsReader(table, len(table)*8))
+		if !errors.Is(err, ErrCorruptTable) {
+			t.Errorf("%s: got %v, want ErrCorruptTable", name, err)
+		}
+	}
+}
+
+// files of the first release are detected and decoded as LegacyVersion
+func TestDecodeLegacyFile(t *testing.T) {
+	for _, name := range append(legacyFixtures, "empty") {
+		encoded, original := readLegacyFixture(t, name)
+		reader := NewReader(bytes.NewReader(encoded))
+		header, err := reader.Header()
+		if err != nil || header.Version != LegacyVersion {
+			t.Fatalf("%s: header version %d, %v", name, header.Version, err)
+		}
+		text, err := io.ReadAll(reader)
+		if err != nil || !bytes.Equal(text, original) {
+			t.Fatalf("%s: reader decoded %q, %v", name, text, err)
+		}
+		text, err = DecodeBytes(encoded)
+		if err != nil || !bytes.Equal(text, original) {
+			t.Fatalf("%s: DecodeBytes decoded %q, %v", name, text, err)
+		}
+
+		info, err := ReadInfo(bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatalf("%s: read info failed: %v", name, err)
+		}
+		if info.Header.Version != LegacyVersion || len(info.Blocks) != 1 || info.Size != int64(len(encoded)) {
+			t.Fatalf("%s: info version %d, %d blocks, size %d", name, info.Header.Version, len(info.Blocks), info.Size)
+		}
+	}
+
+	// other files are not huffman files, whether they look legacy or not
+	for _, data := range []string{"1 plain text", "plain text", "\xff"} {
+		_, err := DecodeBytes([]byte(data))
+		if !errors.Is(err, ErrNotHuffman) {
+			t.Errorf("%q: got %v, want ErrNotHuffman", data, err)
+		}
+	}
+}
+
+// encoded stream of a single block of size bytes with table and no encoded data
+func tableStream(t *testing.T, size uint32, table []byte) []byte {
+	var buffer bytes.Buffer
+	if _, err := writeHeader(&buffer, Header{Size: -1, Checksum: ChecksumNone}); err != nil {
+		t.Fatal(err)
+	}
+	binary.Write(&buffer, binary.BigEndian, size)
+	buffer.Write(table)
+	binary.Write(&buffer, binary.BigEndian, uint64(0))
+	return buffer.Bytes()
+}
+
+// invalid huffman tables are rejected as ErrCorruptTable, in *FormatError when decoding
+func TestReadHuffmanTableCorrupt(t *testing.T) {
+	var tests = map[string][]byte{
+		"duplicate symbol": {tableList, 1, 'a', 1, 'a', 1},
+		"kraft sum above":  {tableList, 2, 'a', 1, 'b', 1, 'c', 1},
+		"kraft sum below":  {tableList, 2, 'a', 1, 'b', 2, 'c', 3},
+		"single width 2":   {tableList, 0, 'a', 2},
+		"width 0":          {tableList, 1, 'a', 1, 'b', 0},
+		"width 65":         {tableList, 1, 'a', 1, 'b', 65},
+		"unknown type":     {tableBitmap + 1},
+		"unknown type 255": {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+	for name, table := range tests {
+		_, err := readHuffmanTable(NewBitsReader(table, len(table)*8))
+		if !errors.Is(err, ErrCorruptTable) {
+			t.Errorf("%s: read table got %v, want ErrCorruptTable", name, err)
+		}
+
+		var data []byte = tableStream(t, 1, table)
+		_, err = DecodeBytes(data)
+		var formatErr *FormatError
+		if !errors.Is(err, ErrCorruptTable) || !errors.As(err, &formatErr) {
+			t.Errorf("%s: decode got %v, want *FormatError with ErrCorruptTable", name, err)
+		} else if formatErr.Offset != headerSize {
+			t.Errorf("%s: error at offset %d, want block offset %d", name, formatErr.Offset, headerSize)
+		}
+		_, err = ReadInfo(bytes.NewReader(data))
+		if !errors.Is(err, ErrCorruptTable) {
+			t.Errorf("%s: read info got %v, want ErrCorruptTable", name, err)
+		}
+	}
+}
+
+// encode text with options for tests
+func encodeText(t *testing.T, text []byte, options *EncodeOptions) []byte {
+	var buffer bytes.Buffer
+	var writer *Writer = NewWriter(&buffer, options)
+	writer.Write(text)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// exceeded decode limits are returned as *LimitError naming the limit
+func TestDecodeLimits(t *testing.T) {
+	var text []byte = benchmarkText(100)
+	var sized []byte = encodeText(t, text, nil)
+	var blocks []byte = encodeText(t, text, &EncodeOptions{BlockSize: 10})
+	legacy, _ := readLegacyFixture(t, "text")
+	var tests = []struct {
+		name    string
+		data    []byte
+		options DecodeOptions
+		limit   string
+	}{
+		{"header size", sized, DecodeOptions{MaxOutputSize: 50}, "MaxOutputSize"},
+		{"block size", blocks, DecodeOptions{MaxOutputSize: 50}, "MaxOutputSize"},
+		{"block table", blocks, DecodeOptions{MaxTableSize: 2}, "MaxTableSize"},
+		{"block memory", blocks, DecodeOptions{MaxMemory: 12}, "MaxMemory"},
+		{"legacy output", legacy, DecodeOptions{MaxOutputSize: 100}, "MaxOutputSize"},
+		{"legacy table", legacy, DecodeOptions{MaxTableSize: 10}, "MaxTableSize"},
+		{"legacy memory", legacy, DecodeOptions{MaxMemory: 200}, "MaxMemory"},
+	}
+	for _, test := range tests {
+		_, err := io.ReadAll(NewReaderWithOptions(bytes.NewReader(test.data), &test.options))
+		var limitErr *LimitError
+		if !errors.Is(err, ErrLimitExceeded) || !errors.As(err, &limitErr) {
+			t.Errorf("%s: got %v, want *LimitError", test.name, err)
+			continue
+		}
+		if limitErr.Limit != test.limit || limitErr.Value <= limitErr.Max {
+			t.Errorf("%s: got limit %s, value %d, max %d, want %s", test.name, limitErr.Limit, limitErr.Value, limitErr.Max, test.limit)
+		}
+	}
+}
+
+// data that only looks like a legacy file is rejected, legacy files have a single block
+func TestDecodeLegacyGarbage(t *testing.T) {
+	single, _ := readLegacyFixture(t, "single")
+	var inputs = map[string][]byte{
+		"zeros 99":       make([]byte, 99),
+		"zeros 10":       make([]byte, 10),
+		"trailing bytes": append(bytes.Clone(single), 0),
+		"two blocks":     append(bytes.Clone(single), single...),
+	}
+	var random *rand.Rand = rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		var data []byte = make([]byte, 1+random.Intn(300))
+		random.Read(data)
+		data[0] = byte(random.Intn(MaxCodeWidth + 1))
+		inputs[fmt.Sprintf("random %d", i)] = data
+	}
+	for name, data := range inputs {
+		_, err := DecodeBytes(data)
+		var formatErr *FormatError
+		if !errors.As(err, &formatErr) {
+			t.Errorf("%s: decode got %v, want *FormatError", name, err)
+		}
+		_, err = ReadInfo(bytes.NewReader(data))
+		if err == nil {
+			t.Errorf("%s: read info succeeded", name)
+		}
+	}
+	_, err := DecodeBytes(make([]byte, 99))
+	if !errors.Is(err, ErrNotHuffman) {
+		t.Errorf("zeros: got %v, want ErrNotHuffman", err)
+	}
+}
@@ -0,0 +1,138 @@
+package huffman
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// number of times each input is encoded
+const determinismRuns = 100
+
+// inputs with many equal frequencies, where map order would show in the output
+var determinismInputs = map[string][]byte{
+	"empty":     {},
+	"single":    []byte("aaaa"),
+	"ties":      []byte("\x00\x00\x01\x01abcdefgh"),
+	"zero tie":  []byte("\x00\x00\x00\x00abcd\x01\x01\x02\x02"),
+	"all bytes": allBytes(4),
+	"text":      bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog\n"), 100),
+}
+
+// each byte value repeated count times
+func allBytes(count int) (ret []byte) {
+	for i := 0; i < 256*count; i++ {
+		ret = append(ret, byte(i))
+	}
+	return ret
+}
+
+// encoding the same input must give the same bytes every time
+func TestEncodeDeterministic(t *testing.T) {
+	var options = []*EncodeOptions{
+		nil,
+		{BlockSize: 5},
+		{MaxCodeWidth: 9, Checksum: ChecksumXXH64},
+	}
+	for name, input := range determinismInputs {
+		for _, option := range options {
+			var first []byte
+			for run := 0; run < determinismRuns; run++ {
+				var buffer bytes.Buffer
+				_, _, err := EncodeStream(context.Background(), bytes.NewReader(input), &buffer, option)
+				if err != nil {
+					t.Fatalf("%s: encode failed: %v", name, err)
+				}
+				if run == 0 {
+					first = buffer.Bytes()
+					continue
+				}
+				if !bytes.Equal(buffer.Bytes(), first) {
+					t.Fatalf("%s: run %d with options %+v differs from first run", name, run, option)
+				}
+			}
+		}
+	}
+}
+
+// the huffman tree must not depend on the order the frequence map is iterated
+func TestFrequenceTreeDeterministic(t *testing.T) {
+	for name, input := range determinismInputs {
+		if len(input) == 0 {
+			continue
+		}
+		var frequence map[byte]int = getFrequence(string(input))
+		first, err := treeToCodes(frequenceToTree(frequence))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for run := 1; run < determinismRuns; run++ {
+			codes, err := treeToCodes(frequenceToTree(frequence))
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			for char, code := range first {
+				if codes[char] != code {
+					t.Fatalf("%s: run %d: code of %d is %v, first run %v", name, run, char, codes[char], code)
+				}
+			}
+		}
+	}
+}
+
+// encoding a file gives the same bytes whatever its access time is,
+// and whatever its times are without metadata
+func TestEncodeFileDeterministic(t *testing.T) {
+	var dir string = t.TempDir()
+	var input string = filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, determinismInputs["text"], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var encode = func(run int, options *EncodeOptions) []byte {
+		// reading input may change its access time, change it explicitly
+		var atime time.Time = time.Unix(1600000000+int64(run), 0)
+		if err := os.Chtimes(input, atime, time.Unix(1600000000, 0)); err != nil {
+			t.Fatal(err)
+		}
+		var output string = filepath.Join(dir, "output.bin")
+		var encodeOptions EncodeOptions = *options
+		encodeOptions.Force = true
+		if _, _, err := EncodeContext(context.Background(), input, output, &encodeOptions); err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	for _, options := range []*EncodeOptions{{}, {NoMetadata: true}} {
+		var first []byte = encode(0, options)
+		for run := 1; run < 10; run++ {
+			if !bytes.Equal(encode(run, options), first) {
+				t.Fatalf("options %+v: run %d differs from first run", *options, run)
+			}
+		}
+	}
+
+	// access time is stored only on request
+	info, err := ReadInfo(bytes.NewReader(encode(3, &EncodeOptions{AccessTime: true})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1600000003, 0); !info.Header.AccessTime.Equal(want) && !info.Header.AccessTime.IsZero() {
+		t.Errorf("stored access time %v, want %v", info.Header.AccessTime, want)
+	}
+	info, err = ReadInfo(bytes.NewReader(encode(4, &EncodeOptions{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Header.AccessTime.IsZero() || !info.Header.ModTime.Equal(time.Unix(1600000000, 0)) {
+		t.Errorf("stored access time %v and modification time %v by default", info.Header.AccessTime, info.Header.ModTime)
+	}
+}
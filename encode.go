@@ -1,18 +1,21 @@
-package main
+package huffman
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"sync"
 	"time"
 )
 
 type EncodeSize struct {
-	orininal     int // in bytes
-	HuffmanTable int // in bytes
+	Original     int // in bytes
+	HuffmanTable int // in bytes, including file and block headers
 	EncodedData  int // in bytes
+	Checksum     Checksum
 }
 
 type EncodeTime struct {
@@ -20,11 +23,20 @@ type EncodeTime struct {
 	WriteFileTime time.Duration // in milliseconds
 }
 
+// error of a single file in batch mode
 type BatchError struct {
 	Path string
 	Err  error
 }
 
+func (err BatchError) Error() string {
+	return err.Path + ": " + err.Err.Error()
+}
+
+func (err BatchError) Unwrap() error {
+	return err.Err
+}
+
 type BatchEncodeResult struct {
 	InputPath    string
 	OutputPath   string
@@ -34,80 +46,266 @@ type BatchEncodeResult struct {
 	EncodedSize  int
 	Time         time.Duration
 	Errors       []BatchError
+	Skipped      []BatchError // files whose output already exists
 }
 
 // write to output file
 //
 // return input size and output size(in bytes) and ok
 //
-// format:
+// input is read and encoded block by block, see Writer for the format
 //
-//	n group of:
-//	    1 byte   : code width (in bits)
-//	    1 byte   : character
-//	    m bytes  : code, low bits valid, MSB first (m = code store width / 8, rounded up)
-//	1 byte   : 0 (end of table)
-//	8 bytes  : encoded data width (in bits)
-//	n bytes  : encoded data
+// output is deterministic for the same data, options, file name, mode and
+// modification time, access time is stored only with AccessTime of options
+// as reading the input changes it, EncodeStream stores no metadata
 func Encode(inputPath, outputPath string) (encodeSize EncodeSize, encodeTime EncodeTime, err error) {
+	return EncodeWithOptions(inputPath, outputPath, nil)
+}
+
+// same as Encode, options may be nil to use default options
+func EncodeWithOptions(inputPath, outputPath string, options *EncodeOptions) (encodeSize EncodeSize, encodeTime EncodeTime, err error) {
+	return EncodeContext(context.Background(), inputPath, outputPath, options)
+}
+
+// same as EncodeWithOptions, stop when ctx is done
+//
+// output file is not created if encoding fails or is canceled
+func EncodeContext(ctx context.Context, inputPath, outputPath string, options *EncodeOptions) (encodeSize EncodeSize, encodeTime EncodeTime, err error) {
 	// record start time
 	var startTime time.Time = time.Now()
+	if options == nil {
+		options = &EncodeOptions{}
+	}
+	var event ProgressEvent = ProgressEvent{Path: inputPath, OutputPath: outputPath, Total: -1}
+	defer func() {
+		event.Type = ProgressFileFinished
+		event.Err = err
+		event.Time = time.Since(startTime)
+		event.EncodeSize = encodeSize
+		options.Progress.report(event)
+	}()
+	err = ctx.Err()
+	if err != nil {
+		return encodeSize, encodeTime, err
+	}
 
-	// read input file
-	var text []byte
-	text, err = os.ReadFile(inputPath)
+	// check output before any work
+	err = checkOutputPath(inputPath, outputPath, options.Force)
 	if err != nil {
-		return encodeSize, encodeTime, fmt.Errorf("open input file %s failed: %v", inputPath, err.Error())
+		return encodeSize, encodeTime, err
 	}
 
-	// get huffman codes
-	var codes HuffmanCodes
-	codes, err = GetHuffmanCodes(string(text))
+	// open input file
+	var inputFile *os.File
+	inputFile, err = os.Open(inputPath)
 	if err != nil {
-		return encodeSize, encodeTime, fmt.Errorf("generate huffman codes failed: %v", err.Error())
+		return encodeSize, encodeTime, fmt.Errorf("open input file %s failed: %w", inputPath, err)
 	}
-	var codeGenTime time.Time = time.Now()
+	defer inputFile.Close()
+	var inputInfo os.FileInfo
+	inputInfo, err = inputFile.Stat()
+	if err != nil {
+		return encodeSize, encodeTime, fmt.Errorf("stat input file %s failed: %w", inputPath, err)
+	}
+	event.Type = ProgressFileStarted
+	if inputInfo.Mode().IsRegular() {
+		event.Total = inputInfo.Size()
+	}
+	options.Progress.report(event)
 
 	// create output directory and file
-	var outputFile *os.File
+	var outputFile *AtomicFile
 	outputFile, err = OpenFile(outputPath)
 	if err != nil {
-		return encodeSize, encodeTime, fmt.Errorf("open output file %s failed: %v", outputPath, err.Error())
+		return encodeSize, encodeTime, fmt.Errorf("open output file %s failed: %w", outputPath, err)
 	}
 	defer outputFile.Close()
 
-	// write huffman table
-	var huffmanTableSize int
-	huffmanTableSize, err = writeHuffmanTable(outputFile, codes)
+	// encode input to output
+	var buffered *bufio.Writer = bufio.NewWriter(outputFile)
+	var writer *Writer = NewWriter(buffered, options)
+	// pipes and other special files report no size, see EncodeStream
+	if inputInfo.Mode().IsRegular() {
+		writer.Header.Size = inputInfo.Size()
+	}
+	if !options.NoMetadata {
+		setFileMetadata(&writer.Header, inputInfo, options.AccessTime)
+	}
+	var input *progressReader = newProgressReader(ctx, inputFile, options.Progress, event)
+	_, err = io.Copy(writer, input)
+	event.Bytes = input.event.Bytes
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return encodeSize, encodeTime, fmt.Errorf("encode file %s canceled: %w", inputPath, ctxErr)
+	}
 	if err != nil {
-		return encodeSize, encodeTime, err
+		return encodeSize, encodeTime, fmt.Errorf("encode file %s failed: %w", inputPath, err)
+	}
+	err = writer.Close()
+	if err != nil {
+		return encodeSize, encodeTime, fmt.Errorf("encode file %s failed: %w", inputPath, err)
+	}
+	err = buffered.Flush()
+	if err != nil {
+		return encodeSize, encodeTime, fmt.Errorf("write encoded data to file %s failed: %w", outputPath, err)
 	}
 
-	// write string
-	var dataSize int
-	dataSize, err = writeString(outputFile, text, codes)
+	// save output only when it decodes to the same data as input
+	if options.Verify || options.RemoveInput {
+		_, err = outputFile.Seek(0, io.SeekStart)
+		if err == nil {
+			err = verifyEncoded(inputPath, outputFile)
+		}
+		if err != nil {
+			return encodeSize, encodeTime, fmt.Errorf("verify output file %s failed, output not saved: %w", outputPath, err)
+		}
+	}
+	err = outputFile.Commit()
 	if err != nil {
-		return encodeSize, encodeTime, err
+		return encodeSize, encodeTime, fmt.Errorf("save output file %s failed: %w", outputPath, err)
+	}
+
+	// output is verified, remove input
+	if options.RemoveInput {
+		inputFile.Close()
+		err = os.Remove(inputPath)
+		if err != nil {
+			return encodeSize, encodeTime, fmt.Errorf("remove input file %s failed: %w", inputPath, err)
+		}
 	}
-	var writeFileTime time.Time = time.Now()
 
 	// write size and time record
-	encodeSize = EncodeSize{
-		orininal:     len(text),
-		HuffmanTable: huffmanTableSize,
-		EncodedData:  dataSize,
+	encodeSize = writer.size
+	encodeTime = EncodeTime{
+		CodeGenTime:   writer.codeGenTime,
+		WriteFileTime: time.Since(startTime) - writer.codeGenTime,
+	}
+	return encodeSize, encodeTime, nil
+}
+
+// encode data read from r and write it to w, stop when ctx is done
+//
+// r and w need not be seekable, original size is stored in the header when r
+// is a regular file or the whole input fits in the first block, no file
+// metadata is stored
+//
+// only BlockSize, MaxCodeWidth and Checksum of options are used
+func EncodeStream(ctx context.Context, r io.Reader, w io.Writer, options *EncodeOptions) (encodeSize EncodeSize, encodeTime EncodeTime, err error) {
+	// record start time
+	var startTime time.Time = time.Now()
+	err = ctx.Err()
+	if err != nil {
+		return encodeSize, encodeTime, err
+	}
+
+	var buffered *bufio.Writer = bufio.NewWriter(w)
+	var writer *Writer = NewWriter(buffered, options)
+	if file, ok := r.(*os.File); ok {
+		if info, statErr := file.Stat(); statErr == nil && info.Mode().IsRegular() {
+			writer.Header.Size = info.Size()
+		}
+	}
+	_, err = io.Copy(writer, newProgressReader(ctx, r, nil, ProgressEvent{}))
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return encodeSize, encodeTime, fmt.Errorf("encode stream canceled: %w", ctxErr)
 	}
+	if err != nil {
+		return encodeSize, encodeTime, fmt.Errorf("encode stream failed: %w", err)
+	}
+	err = writer.Close()
+	if err != nil {
+		return encodeSize, encodeTime, fmt.Errorf("encode stream failed: %w", err)
+	}
+	err = buffered.Flush()
+	if err != nil {
+		return encodeSize, encodeTime, fmt.Errorf("write encoded data failed: %w", err)
+	}
+
+	// write size and time record
+	encodeSize = writer.size
 	encodeTime = EncodeTime{
-		CodeGenTime:   codeGenTime.Sub(startTime),
-		WriteFileTime: writeFileTime.Sub(codeGenTime),
+		CodeGenTime:   writer.codeGenTime,
+		WriteFileTime: time.Since(startTime) - writer.codeGenTime,
+	}
+	return encodeSize, encodeTime, nil
+}
+
+// decode encoded data and compare it with original file
+//
+// return error wrapping ErrVerifyMismatch if decoded data differs
+func verifyEncoded(originalPath string, encoded io.Reader) (err error) {
+	var originalFile *os.File
+	originalFile, err = os.Open(originalPath)
+	if err != nil {
+		return err
+	}
+	defer originalFile.Close()
+
+	// compare chunk by chunk
+	var original *bufio.Reader = bufio.NewReader(originalFile)
+	var reader *Reader = NewReader(encoded)
+	var decoded, expected []byte = make([]byte, 32*1024), make([]byte, 32*1024)
+	var offset int64 = 0
+	for {
+		n, readErr := io.ReadFull(reader, decoded)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return readErr
+		}
+		_, err = io.ReadFull(original, expected[:n])
+		if err != nil {
+			return fmt.Errorf("%w: decoded data longer than original at offset %d", ErrVerifyMismatch, offset+int64(n))
+		}
+		if !bytes.Equal(decoded[:n], expected[:n]) {
+			return fmt.Errorf("%w: decoded data differs from original near offset %d", ErrVerifyMismatch, offset)
+		}
+		offset += int64(n)
+		if readErr != nil {
+			break
+		}
+	}
+	if _, err = original.ReadByte(); err != io.EOF {
+		return fmt.Errorf("%w: decoded data shorter than original at offset %d", ErrVerifyMismatch, offset)
+	}
+	return nil
+}
+
+// encode bytes in memory
+//
+// return encoded bytes in the same format as Encode
+func EncodeBytes(text []byte) (encoded []byte, encodeSize EncodeSize, err error) {
+	var buffer bytes.Buffer
+	var writer *Writer = NewWriter(&buffer, nil)
+	_, err = writer.Write(text)
+	if err != nil {
+		return nil, encodeSize, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, encodeSize, err
 	}
-	return encodeSize, encodeTime, err
+	return buffer.Bytes(), writer.size, nil
 }
 
 func BatchEncode(inputPath string, outputPath string) (result BatchEncodeResult, err error) {
+	return BatchEncodeWithOptions(inputPath, outputPath, nil)
+}
+
+// same as BatchEncode, options are used for each file
+func BatchEncodeWithOptions(inputPath string, outputPath string, options *EncodeOptions) (result BatchEncodeResult, err error) {
+	return BatchEncodeContext(context.Background(), inputPath, outputPath, options)
+}
+
+// same as BatchEncodeWithOptions, stop when ctx is done
+//
+// files not finished when ctx is done are reported as errors, and ctx.Err() is returned with the result
+func BatchEncodeContext(ctx context.Context, inputPath string, outputPath string, options *EncodeOptions) (result BatchEncodeResult, err error) {
 	// record start time
 	var startTime time.Time = time.Now()
 	var errors []BatchError = make([]BatchError, 0)
+	var fileOptions EncodeOptions
+	if options != nil {
+		fileOptions = *options
+	}
+	fileOptions.Progress = fileOptions.Progress.serialized()
 
 	// normalize input & output paths
 	inputPath = filepath.Clean(inputPath)
@@ -118,84 +316,139 @@ func BatchEncode(inputPath string, outputPath string) (result BatchEncodeResult,
 	var getFilesErrors []BatchError
 	inputFiles, getFilesErrors, err = GetFilesInDir(inputPath)
 	if err != nil {
-		return result, fmt.Errorf("get input files failed: %v", err.Error())
+		return result, fmt.Errorf("get input files failed: %w", err)
 	}
 	errors = append(errors, getFilesErrors...)
 
 	// get output paths for input files
 	var outputPaths []string
 	var getOutputPathErrors []BatchError
-	outputPaths, getOutputPathErrors = GetOutputPaths(inputFiles, outputPath, "bin")
+	var totalCount int = len(inputFiles)
+	inputFiles, outputPaths, getOutputPathErrors = GetOutputPaths(inputPath, inputFiles, outputPath, "bin")
 	errors = append(errors, getOutputPathErrors...)
+	reportBatchErrors(fileOptions.Progress, errors)
 
-	// process each file with goroutines
+	// files whose output is an input file are skipped, not reported as errors
+	var skipped []BatchError = make([]BatchError, 0)
+	var pathErrors []BatchError = errors
+	errors = make([]BatchError, 0, len(pathErrors))
+	for _, pathErr := range pathErrors {
+		if isSkipped(pathErr.Err) {
+			skipped = append(skipped, pathErr)
+		} else {
+			errors = append(errors, pathErr)
+		}
+	}
+
+	// process files with a bounded number of goroutines
+	var encodeSizes []EncodeSize = make([]EncodeSize, len(inputFiles))
+	var encodeErrors []error = make([]error, len(inputFiles))
+	runBatch(ctx, len(inputFiles), fileOptions.Jobs, fileOptions.BatchMemory, func(idx int) int64 {
+		return encodeMemory(inputFiles[idx], &fileOptions)
+	}, func(idx int) {
+		encodeSizes[idx], _, encodeErrors[idx] = EncodeContext(ctx, inputFiles[idx], outputPaths[idx], &fileOptions)
+	})
+
+	// collect results in input order
 	var success int = 0
 	var originalSum int = 0
 	var encodedSum int = 0
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	for i, inputPath := range inputFiles {
-		wg.Add(1)
-		go func(idx int, inPath string) {
-			defer wg.Done()
-			// encode
-			encSize, _, encErr := Encode(inPath, outputPaths[idx])
-			mu.Lock()
-			defer mu.Unlock()
-			if encErr != nil {
-				errors = append(errors, BatchError{Path: inPath, Err: encErr})
-			} else {
-				success++
-				// accumulate sizes
-				originalSum += encSize.orininal
-				encodedSum += encSize.HuffmanTable + encSize.EncodedData
-			}
-		}(i, inputPath)
+	for idx, encErr := range encodeErrors {
+		if isSkipped(encErr) {
+			skipped = append(skipped, BatchError{Path: inputFiles[idx], Err: encErr})
+		} else if encErr != nil {
+			errors = append(errors, BatchError{Path: inputFiles[idx], Err: encErr})
+		} else {
+			success++
+			// accumulate sizes
+			originalSum += encodeSizes[idx].Original
+			encodedSum += encodeSizes[idx].HuffmanTable + encodeSizes[idx].EncodedData
+		}
 	}
-
-	wg.Wait()
+	sortBatchErrors(errors)
+	sortBatchErrors(skipped)
 
 	// fill result
 	result = BatchEncodeResult{
 		InputPath:    inputPath,
 		OutputPath:   outputPath,
-		TotalCount:   len(inputFiles),
+		TotalCount:   totalCount,
 		SuccessCount: success,
 		OriginalSize: originalSum,
 		EncodedSize:  encodedSum,
 		Time:         time.Since(startTime),
 		Errors:       errors,
+		Skipped:      skipped,
 	}
-	return result, nil
+	return result, ctx.Err()
 }
 
+// huffman table types
+const (
+	tableEmpty  = 0 // no character, for empty block
+	tableList   = 1 // list of (character, code width)
+	tableBitmap = 2 // bitmap of characters and list of code width
+)
+
+// size of character bitmap in bitmap table (in bytes)
+const tableBitmapSize = 256 / 8
+
 // write huffman table to file
 //
+// codes must be canonical, only code widths are written and
+// codes are rebuilt by canonicalCodes when reading
+//
+// the smaller one of list and bitmap table is written
+//
 // return size written(in bytes) and ok
 //
 // format:
 //
-//	n group of:
-//	    1 byte   : code width (in bits)
-//	    1 byte   : character
-//	    m bytes  : code, low bits valid, MSB first (m = code store width / 8, rounded up)
-//	1 byte   : 0 (end of table)
+//	1 byte   : table type
+//	list table:
+//	    1 byte   : number of characters - 1
+//	    n group of:
+//	        1 byte   : character
+//	        1 byte   : code width (in bits)
+//	bitmap table:
+//	    32 bytes : bitmap of characters, bit i set if character i exists, MSB first
+//	    n bytes  : code width (in bits) of each character, in character order
 func writeHuffmanTable(file io.Writer, codes HuffmanCodes) (size int, err error) {
 	var recorder *BitsRecorder = NewBitsRecorder()
 
-	// write each code
-	for char, code := range codes {
-		// code width
-		recorder.Add(uint64(code.Width), 8)
-		// character
-		recorder.Add(uint64(char), 8)
-		// code
-		var codeStoreLength uint8 = (code.Width + 7) / 8 * 8
-		recorder.Add(code.Code, codeStoreLength)
-	}
-	// end of table
-	recorder.Add(0, 8)
+	switch {
+	case len(codes) == 0:
+		recorder.Add(tableEmpty, 8)
+
+	case 1+2*len(codes) < tableBitmapSize+len(codes):
+		recorder.Add(tableList, 8)
+		recorder.Add(uint64(len(codes)-1), 8)
+		for char := 0; char < 256; char++ {
+			code, ok := codes[byte(char)]
+			if !ok {
+				continue
+			}
+			recorder.Add(uint64(char), 8)
+			recorder.Add(uint64(code.Width), 8)
+		}
+
+	default:
+		recorder.Add(tableBitmap, 8)
+		// bitmap
+		for char := 0; char < 256; char++ {
+			var bit uint8 = 0
+			if _, ok := codes[byte(char)]; ok {
+				bit = 1
+			}
+			recorder.AddBit(bit)
+		}
+		// code widths
+		for char := 0; char < 256; char++ {
+			if code, ok := codes[byte(char)]; ok {
+				recorder.Add(uint64(code.Width), 8)
+			}
+		}
+	}
 
 	// write to file
 	size, err = file.Write(recorder.Result())
@@ -0,0 +1,78 @@
+package huffman
+
+import (
+	"errors"
+	"fmt"
+)
+
+// input is neither a current nor a legacy huffman encoded file
+var ErrNotHuffman = errors.New("not a huffman encoded file")
+
+// input is written by a newer version or uses unknown features
+var ErrUnsupportedVersion = errors.New("unsupported huffman file format")
+
+// checksum of decoded data does not match the checksum stored in the file
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// metadata section of header can not be read or contains invalid entries
+var ErrCorruptMetadata = errors.New("corrupt file metadata")
+
+// huffman table can not be read or describes invalid codes
+var ErrCorruptTable = errors.New("corrupt huffman table")
+
+// input ends before the encoded data it declares
+var ErrTruncatedData = errors.New("truncated encoded data")
+
+// encoded data contains bits that do not form a valid code
+var ErrInvalidCode = errors.New("invalid huffman code in encoded data")
+
+// decoded size does not match the size declared in the file
+var ErrSizeMismatch = errors.New("decoded size mismatch")
+
+// output file exists and overwriting is not allowed
+var ErrOutputExists = errors.New("output file already exists")
+
+// encoded output does not decode to the input, see EncodeOptions.Verify
+var ErrVerifyMismatch = errors.New("decoded data differs from input")
+
+// encoded file has no checksum, so decoded data can not be verified before removing input
+var ErrNoChecksum = errors.New("encoded file has no checksum")
+
+// several input files have the same output file in batch mode
+var ErrOutputCollision = errors.New("output path collision")
+
+// error in encoded input
+//
+// Offset is the position (in bytes) of the header or block containing the error
+type FormatError struct {
+	Offset int64
+	Err    error
+}
+
+func (err *FormatError) Error() string {
+	return fmt.Sprintf("format error at offset %d: %v", err.Offset, err.Err)
+}
+
+func (err *FormatError) Unwrap() error {
+	return err.Err
+}
+
+// a limit of DecodeOptions is exceeded, returned as *LimitError
+var ErrLimitExceeded = errors.New("decode limit exceeded")
+
+// error of exceeded limit
+//
+// Limit is the name of the field in DecodeOptions, Value is the size required
+type LimitError struct {
+	Limit string
+	Value int64
+	Max   int64
+}
+
+func (err *LimitError) Error() string {
+	return fmt.Sprintf("%v: %s %d, required at least %d", ErrLimitExceeded, err.Limit, err.Max, err.Value)
+}
+
+func (err *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
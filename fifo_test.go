@@ -0,0 +1,47 @@
+//go:build unix
+
+package huffman
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// named pipes report size 0, encoding must not trust it
+func TestEncodeFIFO(t *testing.T) {
+	var dir string = t.TempDir()
+	var input string = filepath.Join(dir, "input")
+	if err := syscall.Mkfifo(input, 0o600); err != nil {
+		t.Skipf("create named pipe failed: %v", err)
+	}
+	var text []byte = benchmarkText(3000)
+	var done chan error = make(chan error, 1)
+	go func() {
+		file, err := os.OpenFile(input, os.O_WRONLY, 0)
+		if err == nil {
+			_, err = file.Write(text)
+			file.Close()
+		}
+		done <- err
+	}()
+
+	var encoded string = filepath.Join(dir, "input.bin")
+	_, _, err := EncodeContext(context.Background(), input, encoded, &EncodeOptions{BlockSize: 1000})
+	if err != nil {
+		t.Fatalf("encode named pipe failed: %v", err)
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeBytes(data)
+	if err != nil || string(decoded) != string(text) {
+		t.Fatalf("decoded %d bytes, want %d, %v", len(decoded), len(text), err)
+	}
+}
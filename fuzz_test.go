@@ -0,0 +1,224 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// limits of fuzzed decoding, keep each run small
+var fuzzDecodeOptions = DecodeOptions{
+	MaxOutputSize: 1 << 20,
+	MaxTableSize:  1 << 10,
+	MaxMemory:     4 << 20,
+}
+
+// encode seed text with options
+func fuzzEncode(f *testing.F, text []byte, options *EncodeOptions) []byte {
+	var buffer bytes.Buffer
+	var writer *Writer = NewWriter(&buffer, options)
+	writer.Write(text)
+	if err := writer.Close(); err != nil {
+		f.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// arbitrary bytes through the whole decode path must never panic
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{tableEmpty})
+	f.Add(fuzzEncode(f, []byte("hello, huffman"), nil))
+	f.Add(fuzzEncode(f, []byte("aaaaaaaaaaaaaaaabbbbbbbbccccdde"), &EncodeOptions{BlockSize: 7, Checksum: ChecksumXXH64}))
+	f.Add(fuzzEncode(f, bytes.Repeat([]byte{0, 1, 2, 255}, 64), &EncodeOptions{MaxCodeWidth: 8, Checksum: ChecksumSHA256}))
+	legacy, _ := os.ReadFile(filepath.Join("testdata", "legacy", "text.bin"))
+	f.Add(legacy)
+	var metadata bytes.Buffer
+	var writer *Writer = NewWriter(&metadata, nil)
+	writer.Header.Name = "name.txt"
+	writer.Header.Mode = 0o644
+	writer.Header.ModTime = time.Unix(1600000000, 0)
+	writer.Write([]byte("metadata"))
+	writer.Close()
+	f.Add(metadata.Bytes())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		info, infoErr := ReadInfo(bytes.NewReader(data))
+		var reader *Reader = NewReaderWithOptions(bytes.NewReader(data), &fuzzDecodeOptions)
+		text, err := io.ReadAll(reader)
+		if err != nil {
+			return
+		}
+
+		// decoded stream must be inspected without error
+		if infoErr != nil {
+			t.Fatalf("read info of decodable data failed: %v", infoErr)
+		}
+		if info.Size > int64(len(data)) || info.TableSize()+info.DataSize() != info.Size {
+			t.Fatalf("info size %d, table %d, data %d, input %d bytes", info.Size, info.TableSize(), info.DataSize(), len(data))
+		}
+		if size := info.OriginalSize(); size >= 0 && size != int64(len(text)) {
+			t.Fatalf("info original size %d, decoded %d bytes", size, len(text))
+		}
+
+		// decoded without error, must decode the same without limits
+		decoded, err := DecodeBytes(data)
+		if err != nil {
+			t.Fatalf("decode without limits failed: %v", err)
+		}
+		if !bytes.Equal(decoded, text) {
+			t.Fatal("decoded text differs without limits")
+		}
+	})
+}
+
+// arbitrary bytes as a huffman table and encoded data must never panic,
+// and the lookup table must decode the same as the tree walker
+func FuzzReadHuffmanTable(f *testing.F) {
+	f.Add([]byte{tableList, 1, 'a', 1, 'b', 1, 0, 0, 0, 0, 0, 0, 0, 3, 0b01000000})
+	f.Add([]byte{tableList, 0, 'a', 1})
+	f.Add([]byte{tableBitmap, 0xff})
+	f.Add(fuzzEncode(f, []byte("abracadabra"), nil)[headerSize+blockHeaderSize:])
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var reader *BitsReader = NewBitsReader(data, len(data)*8)
+		codes, err := readHuffmanTable(reader)
+		if err != nil {
+			return
+		}
+		if len(codes) == 0 {
+			return
+		}
+
+		// valid table, tree must be built
+		tree, err := GetHuffmanTree(codes)
+		if err != nil {
+			t.Fatalf("build tree of valid table failed: %v", err)
+		}
+		var tableReader BitsReader = *reader
+		text, treeErr := readString(reader, tree, 1<<16)
+		if widestCode(codes) > lookupMaxWidth {
+			return
+		}
+		table, err := newLookupTable(codes)
+		if err != nil {
+			t.Fatalf("build lookup table of valid table failed: %v", err)
+		}
+		tableText, tableErr := readStringWithTable(&tableReader, table, 1<<16)
+		if (treeErr == nil) != (tableErr == nil) {
+			t.Fatalf("tree and lookup table disagree: %v, %v", treeErr, tableErr)
+		}
+		if !bytes.Equal(text, tableText) {
+			t.Fatal("tree and lookup table decoded different text")
+		}
+	})
+}
+
+// arbitrary text must survive an encode and decode round trip
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte(""), uint16(0), uint8(0))
+	f.Add([]byte("a"), uint16(1), uint8(0))
+	f.Add([]byte("hello, huffman"), uint16(4), uint8(16))
+	f.Add(bytes.Repeat([]byte("abbcccddddeeeeeffffff"), 16), uint16(100), uint8(4))
+
+	f.Fuzz(func(t *testing.T, text []byte, blockSize uint16, maxWidth uint8) {
+		// codes of the whole text, 8 bits are enough for any text
+		var width int = int(maxWidth)%(MaxCodeWidth-7) + 8
+		codes, err := GetLimitedHuffmanCodes(string(text), width)
+		if err != nil {
+			t.Fatalf("generate codes failed: %v", err)
+		}
+		if len(codes) > 0 {
+			if err = checkCodeWidths(codes); err != nil {
+				t.Fatalf("generated codes are invalid: %v", err)
+			}
+			if _, err = GetHuffmanTree(codes); err != nil {
+				t.Fatalf("generated codes are not prefix-free: %v", err)
+			}
+		}
+
+		// encode and decode
+		var buffer bytes.Buffer
+		var writer *Writer = NewWriter(&buffer, &EncodeOptions{BlockSize: int(blockSize), MaxCodeWidth: width})
+		_, err = writer.Write(text)
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		decoded, err := DecodeBytes(buffer.Bytes())
+		if err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		if !bytes.Equal(decoded, text) {
+			t.Fatal("decoded text differs from original")
+		}
+	})
+}
+
+// arbitrary reads and seeks must never panic and must match reading bit by bit
+func FuzzBitsReader(f *testing.F) {
+	f.Add([]byte{0xa5, 0x0f}, uint16(13), []byte{1, 8, 0x83, 64, 3})
+	f.Add([]byte{}, uint16(0), []byte{0, 1, 2})
+	f.Add([]byte{0xff, 0, 0xff, 0, 0xff, 0, 0xff, 0, 0xff}, uint16(72), []byte{7, 64, 0xc0, 64})
+
+	f.Fuzz(func(t *testing.T, data []byte, width uint16, operations []byte) {
+		var reader *BitsReader = NewBitsReader(data, int(width))
+		if int(width) > len(data)*8 {
+			if reader != nil {
+				t.Fatal("reader created with width beyond data")
+			}
+			return
+		}
+
+		// bit at position, reference of reader
+		var bitAt = func(position int) uint64 {
+			return uint64(data[position/8]>>(7-position%8)) & 1
+		}
+		var position int = 0
+		for _, operation := range operations {
+			// high bit: seek, otherwise read low bits as width
+			if operation&0x80 != 0 {
+				var offset int = int(int8(operation<<1)) * 4
+				reader.Seek(offset)
+				position = min(max(position+offset, 0), int(width))
+				if reader.currentPointer != position {
+					t.Fatalf("seek %d to %d, expected %d", offset, reader.currentPointer, position)
+				}
+				continue
+			}
+
+			var n int = int(operation & 0x7f)
+			var value uint64
+			var ok bool
+			switch n {
+			case 8:
+				var value8 uint8
+				value8, ok = reader.GetUint8()
+				value = uint64(value8)
+			case 64:
+				value, ok = reader.GetUint64()
+			default:
+				value, ok = reader.GetNBits(n)
+			}
+			if n > 64 || n > int(width)-position {
+				if ok {
+					t.Fatalf("read %d bits at %d of %d bits", n, position, width)
+				}
+				continue
+			}
+			var expected uint64
+			for i := 0; i < n; i++ {
+				expected = expected<<1 | bitAt(position+i)
+			}
+			if !ok || value != expected {
+				t.Fatalf("read %d bits at %d: %x, expected %x", n, position, value, expected)
+			}
+			position += n
+		}
+	})
+}
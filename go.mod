@@ -1,3 +1,3 @@
-module huffman
+module github.com/Anslen/huffman
 
 go 1.25.2
@@ -0,0 +1,143 @@
+package huffman
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// magic bytes at the start of each encoded file
+var headerMagic = [4]byte{0x89, 'H', 'U', 'F'}
+
+// format versions
+const (
+	LegacyVersion = 1 // no header, single block of the first release
+	FormatVersion = 2 // current version written by Writer
+)
+
+// header flags
+const (
+	flagSize     = 1 << 0 // original size is known
+	flagChecksum = 3 << 1 // id of checksum algorithm, see checksumID
+	flagMetadata = 1 << 3 // metadata section follows the header
+
+	flagChecksumShift = 1
+	flagsKnown        = flagSize | flagChecksum | flagMetadata
+)
+
+// size of file header without metadata (in bytes)
+const headerSize = 14
+
+// size of block header (in bytes)
+const blockHeaderSize = 4
+
+// file header
+//
+// format:
+//
+//	4 bytes  : magic bytes 0x89 'H' 'U' 'F'
+//	1 byte   : format version
+//	1 byte   : flags
+//	8 bytes  : original size (in bytes), 0 if flagSize not set
+//	metadata (see writeMetadata), only if flagMetadata set
+//
+// legacy files have no header and start with the width of their first huffman code
+type Header struct {
+	Version  int               // format version, set by Reader
+	Size     int64             // original size in bytes, -1 if unknown
+	Checksum ChecksumAlgorithm // checksum stored after end of stream
+
+	// metadata of the original file, zero values are not stored
+	Name       string      // base name
+	Mode       os.FileMode // permission bits, with setuid, setgid and sticky bits
+	ModTime    time.Time   // modification time
+	AccessTime time.Time   // access time
+}
+
+// write header to file
+//
+// return size written(in bytes) and ok
+func writeHeader(file io.Writer, header Header) (size int, err error) {
+	var metadata []byte
+	metadata, err = appendMetadata(nil, header)
+	if err != nil {
+		return 0, err
+	}
+
+	var buffer []byte = make([]byte, headerSize, headerSize+len(metadata))
+	copy(buffer[:4], headerMagic[:])
+	buffer[4] = FormatVersion
+	if header.Size >= 0 {
+		buffer[5] |= flagSize
+		binary.BigEndian.PutUint64(buffer[6:], uint64(header.Size))
+	}
+	buffer[5] |= checksumID(header.Checksum) << flagChecksumShift
+	if len(metadata) > 0 {
+		buffer[5] |= flagMetadata
+		buffer = append(buffer, metadata...)
+	}
+
+	size, err = file.Write(buffer)
+	if err != nil {
+		return size, fmt.Errorf("write header to file failed: %w", err)
+	}
+	return size, nil
+}
+
+// read header from reader, detect legacy files without header
+//
+// return size read(in bytes), 0 for legacy files
+func readHeader(r *bufio.Reader) (header Header, size int, err error) {
+	header.Size = -1
+	header.Checksum = ChecksumNone
+
+	// legacy file starts with a code width, or 0 for an empty table
+	magic, err := r.Peek(len(headerMagic))
+	if err != nil && err != io.EOF {
+		return header, 0, err
+	}
+	if len(magic) < len(headerMagic) || [4]byte(magic) != headerMagic {
+		if len(magic) == 0 || magic[0] > MaxCodeWidth {
+			return header, 0, &FormatError{Offset: 0, Err: ErrNotHuffman}
+		}
+		header.Version = LegacyVersion
+		return header, 0, nil
+	}
+
+	var buffer [headerSize]byte
+	_, err = io.ReadFull(r, buffer[:])
+	if err != nil {
+		return header, 0, &FormatError{Offset: 0, Err: fmt.Errorf("%w: truncated header", ErrNotHuffman)}
+	}
+	header.Version = int(buffer[4])
+	if header.Version != FormatVersion {
+		return header, 0, &FormatError{Offset: 4, Err: fmt.Errorf("%w: version %d", ErrUnsupportedVersion, header.Version)}
+	}
+	var flags byte = buffer[5]
+	if flags&^flagsKnown != 0 {
+		return header, 0, &FormatError{Offset: 5, Err: fmt.Errorf("%w: flags %#x", ErrUnsupportedVersion, flags)}
+	}
+	if flags&flagSize != 0 {
+		var originalSize uint64 = binary.BigEndian.Uint64(buffer[6:])
+		if originalSize > 1<<63-1 {
+			return header, 0, &FormatError{Offset: 6, Err: fmt.Errorf("%w: original size %d too large", ErrNotHuffman, originalSize)}
+		}
+		header.Size = int64(originalSize)
+	}
+	header.Checksum = checksumFromID((flags & flagChecksum) >> flagChecksumShift)
+	size = headerSize
+
+	// metadata
+	if flags&flagMetadata != 0 {
+		var metadataSize int
+		metadataSize, err = readMetadata(r, &header)
+		if err != nil {
+			return header, size, &FormatError{Offset: headerSize, Err: err}
+		}
+		size += metadataSize
+	}
+	return header, size, nil
+}
@@ -1,19 +1,38 @@
-package main
+// Package huffman implements huffman coding for byte slices and files.
+package huffman
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
+
+// default max code width (in bits) used by GetHuffmanCodes
+const DefaultMaxCodeWidth = 24
+
+// max code width (in bits) supported by the file format
+const MaxCodeWidth = 64
 
 type huffmanTree = Tree[huffmanNode]
 type HuffmanCodes = map[byte]HuffmanCode
 
 // compare function for priority queue
+//
+// trees are totally ordered so the huffman tree does not depend on the order
+// trees are pushed in: by frequence, then leaves before internal nodes,
+// leaves by character and internal nodes by order of creation
 func compareHuffmanTree(a1, a2 any) bool {
-	if a1.(*huffmanTree).Value.frequence != a2.(*huffmanTree).Value.frequence {
-		return a1.(*huffmanTree).Value.frequence < a2.(*huffmanTree).Value.frequence
+	var tree1, tree2 *huffmanTree = a1.(*huffmanTree), a2.(*huffmanTree)
+	if tree1.Value.frequence != tree2.Value.frequence {
+		return tree1.Value.frequence < tree2.Value.frequence
 	}
-	if a1.(*huffmanTree).Value.char != a2.(*huffmanTree).Value.char {
-		return a1.(*huffmanTree).Value.char < a2.(*huffmanTree).Value.char
+	var leaf1, leaf2 bool = tree1.Left == nil && tree1.Right == nil, tree2.Left == nil && tree2.Right == nil
+	if leaf1 != leaf2 {
+		return leaf1
 	}
-	return a1.(*huffmanTree).Value.index < a2.(*huffmanTree).Value.index
+	if leaf1 {
+		return tree1.Value.char < tree2.Value.char
+	}
+	return tree1.Value.index < tree2.Value.index
 }
 
 type huffmanNode struct {
@@ -133,24 +152,144 @@ func treeToCodes(tree *huffmanTree) (ret HuffmanCodes, err error) {
 	return ret, nil
 }
 
+// get canonical huffman codes no longer than DefaultMaxCodeWidth
 func GetHuffmanCodes(str string) (codes HuffmanCodes, err error) {
-	if str == "" {
+	return GetLimitedHuffmanCodes(str, DefaultMaxCodeWidth)
+}
+
+// get canonical huffman codes no longer than maxWidth bits
+//
+// codes are optimal under the width limit, return error only if
+// maxWidth is out of range or too small for the number of characters
+func GetLimitedHuffmanCodes(str string, maxWidth int) (codes HuffmanCodes, err error) {
+	return frequenceToCodes(getFrequence(str), maxWidth)
+}
+
+// get canonical huffman codes no longer than maxWidth bits from frequence of each byte
+func frequenceToCodes(frequence map[byte]int, maxWidth int) (codes HuffmanCodes, err error) {
+	if maxWidth < 1 || maxWidth > MaxCodeWidth {
+		return nil, fmt.Errorf("max code width %d out of range [1, %d]", maxWidth, MaxCodeWidth)
+	}
+	if len(frequence) == 0 {
 		return make(HuffmanCodes), nil
 	}
-	frequence := getFrequence(str)
+	if maxWidth < 8 && len(frequence) > 1<<maxWidth {
+		return nil, fmt.Errorf("max code width %d too small for %d characters", maxWidth, len(frequence))
+	}
+
+	// limit code width only when huffman tree is too deep
 	tree := frequenceToTree(frequence)
-	return treeToCodes(tree)
+	if tree.Height()-1 > maxWidth {
+		codes = packageMerge(frequence, maxWidth)
+	} else {
+		codes, err = treeToCodes(tree)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return canonicalCodes(codes), nil
+}
+
+// get the max code width of codes, 0 if codes is empty
+func widestCode(codes HuffmanCodes) (ret uint8) {
+	for _, code := range codes {
+		if code.Width > ret {
+			ret = code.Width
+		}
+	}
+	return ret
+}
+
+// rebuild canonical huffman codes from code widths
+//
+// only Width of each code is used, characters are ordered by (width, char),
+// each code is the previous code plus one, shifted left when width grows
+func canonicalCodes(codes HuffmanCodes) (ret HuffmanCodes) {
+	ret = make(HuffmanCodes, len(codes))
+
+	// sort characters by width, then by character
+	var chars []byte = make([]byte, 0, len(codes))
+	for char := range codes {
+		chars = append(chars, char)
+	}
+	sort.Slice(chars, func(i, j int) bool {
+		if codes[chars[i]].Width != codes[chars[j]].Width {
+			return codes[chars[i]].Width < codes[chars[j]].Width
+		}
+		return chars[i] < chars[j]
+	})
+
+	// assign codes
+	var code uint64 = 0
+	var width uint8 = 0
+	for i, char := range chars {
+		if i > 0 {
+			code++
+		}
+		code <<= codes[char].Width - width
+		width = codes[char].Width
+		ret[char] = HuffmanCode{Code: code, Width: width}
+	}
+	return ret
+}
+
+// check code widths form a complete prefix code (Kraft sum is 1)
+//
+// a single character with width 1 is the only incomplete code allowed
+func checkCodeWidths(codes HuffmanCodes) (err error) {
+	var counts [MaxCodeWidth + 1]int
+	for char, code := range codes {
+		if code.Width == 0 || code.Width > MaxCodeWidth {
+			return fmt.Errorf("%w: invalid code width %d of character %d", ErrCorruptTable, code.Width, char)
+		}
+		counts[code.Width]++
+	}
+	if len(codes) <= 1 {
+		if len(codes) == 1 && counts[1] != 1 {
+			return fmt.Errorf("%w: single character must have code width 1", ErrCorruptTable)
+		}
+		return nil
+	}
+
+	// count unused codes at each width
+	var left int = 1
+	var remaining int = len(codes)
+	for width := 1; width <= MaxCodeWidth; width++ {
+		left <<= 1
+		if counts[width] > left {
+			return fmt.Errorf("%w: too many codes of width %d, Kraft sum above 1", ErrCorruptTable, width)
+		}
+		left -= counts[width]
+		remaining -= counts[width]
+		// unused codes never run out with the remaining characters
+		if left > remaining {
+			return fmt.Errorf("%w: incomplete codes after width %d, Kraft sum below 1", ErrCorruptTable, width)
+		}
+	}
+	return nil
 }
 
 // build huffman tree without frequence from codes map
-func GetHuffmanTree(codes HuffmanCodes) (ret *Tree[byte]) {
+//
+// codes read from a huffman table are canonical, see canonicalCodes
+//
+// return ErrCorruptTable if a code is invalid or codes are not prefix-free
+func GetHuffmanTree(codes HuffmanCodes) (ret *Tree[byte], err error) {
 	ret = NewTree(byte(0))
+	// leaf nodes must not get children
+	var leaves map[*Tree[byte]]bool = make(map[*Tree[byte]]bool, len(codes))
 
 	// insert each code to tree
 	var current *Tree[byte] = ret
 	for char, code := range codes {
+		if code.Width == 0 || code.Width > MaxCodeWidth || code.Code>>code.Width != 0 {
+			return nil, fmt.Errorf("%w: invalid code %b with width %d of character %d", ErrCorruptTable, code.Code, code.Width, char)
+		}
 		reader := NewBitsReaderFromUint64(code.Code, int(code.Width))
 		for i := 0; i < int(code.Width); i++ {
+			if leaves[current] {
+				return nil, fmt.Errorf("%w: code of character %d is prefix of code of character %d", ErrCorruptTable, current.Value, char)
+			}
 			bit, _ := reader.GetBit()
 			// if bit is 0, go left; else go right
 			if bit == 0 {
@@ -165,8 +304,95 @@ func GetHuffmanTree(codes HuffmanCodes) (ret *Tree[byte]) {
 				current = current.Right
 			}
 		}
+		if leaves[current] {
+			return nil, fmt.Errorf("%w: characters %d and %d have the same code", ErrCorruptTable, current.Value, char)
+		}
+		if current.Left != nil || current.Right != nil {
+			return nil, fmt.Errorf("%w: code of character %d is prefix of another code", ErrCorruptTable, char)
+		}
+		leaves[current] = true
 		current.Value = char
 		current = ret
 	}
+	return ret, nil
+}
+
+// node of an exported huffman tree, see GetFrequenceTree and GetDecodeTree
+type HuffmanTreeNode struct {
+	Leaf      bool
+	Char      byte        // leaves only
+	Frequence int         // sum of children for internal nodes, -1 if unknown
+	Index     int         // order of creation of internal nodes of frequence trees, -1 otherwise
+	Code      HuffmanCode // path from the root, 0 for left and 1 for right
+}
+
+// get the huffman tree built from frequence of each byte, nil if frequence is empty
+//
+// this is the tree codes are taken from before they are limited and made canonical,
+// internal nodes keep the order they are created in
+func GetFrequenceTree(frequence map[byte]int) (ret *Tree[HuffmanTreeNode]) {
+	var tree *huffmanTree = frequenceToTree(frequence)
+	if tree == nil {
+		return nil
+	}
+	// single node tree, see treeToCodes
+	if tree.Left == nil && tree.Right == nil {
+		return NewTree(HuffmanTreeNode{Leaf: true, Char: tree.Value.char, Frequence: tree.Value.frequence, Index: -1, Code: HuffmanCode{Code: 0, Width: 1}})
+	}
+	return exportTree(tree, HuffmanCode{}, func(node *huffmanTree, code HuffmanCode) HuffmanTreeNode {
+		if node.Left == nil && node.Right == nil {
+			return HuffmanTreeNode{Leaf: true, Char: node.Value.char, Frequence: node.Value.frequence, Index: -1, Code: code}
+		}
+		return HuffmanTreeNode{Frequence: node.Value.frequence, Index: node.Value.index, Code: code}
+	})
+}
+
+// get the decode tree of codes, see GetHuffmanTree
+//
+// frequence of leaves is taken from frequence, which may be nil if unknown
+func GetDecodeTree(codes HuffmanCodes, frequence map[byte]int) (ret *Tree[HuffmanTreeNode], err error) {
+	var tree *Tree[byte]
+	tree, err = GetHuffmanTree(codes)
+	if err != nil {
+		return nil, err
+	}
+	if len(codes) == 0 {
+		return nil, nil
+	}
+	ret = exportTree(tree, HuffmanCode{}, func(node *Tree[byte], code HuffmanCode) HuffmanTreeNode {
+		if node.Left == nil && node.Right == nil {
+			var count int = -1
+			if frequence != nil {
+				count = frequence[node.Value]
+			}
+			return HuffmanTreeNode{Leaf: true, Char: node.Value, Frequence: count, Index: -1, Code: code}
+		}
+		return HuffmanTreeNode{Frequence: -1, Index: -1, Code: code}
+	})
+	if frequence != nil {
+		sumFrequence(ret)
+	}
+	return ret, nil
+}
+
+// copy tree with value of each node from node and its code
+func exportTree[T any](tree *Tree[T], code HuffmanCode, value func(node *Tree[T], code HuffmanCode) HuffmanTreeNode) (ret *Tree[HuffmanTreeNode]) {
+	if tree == nil {
+		return nil
+	}
+	ret = NewTree(value(tree, code))
+	ret.Left = exportTree(tree.Left, HuffmanCode{Code: code.Code << 1, Width: code.Width + 1}, value)
+	ret.Right = exportTree(tree.Right, HuffmanCode{Code: code.Code<<1 | 1, Width: code.Width + 1}, value)
 	return ret
 }
+
+// set frequence of internal nodes to the sum of their children
+func sumFrequence(tree *Tree[HuffmanTreeNode]) int {
+	if tree == nil {
+		return 0
+	}
+	if !tree.Value.Leaf {
+		tree.Value.Frequence = sumFrequence(tree.Left) + sumFrequence(tree.Right)
+	}
+	return tree.Value.Frequence
+}
@@ -0,0 +1,165 @@
+package huffman
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// layout of an encoded file, read without decoding data
+//
+// sizes split the file as EncodeSize does, table sizes include the header,
+// block headers and the trailer, data sizes include the 8-byte bit width
+type Info struct {
+	Header     Header
+	HeaderSize int64 // header and metadata in bytes, 0 for legacy files
+	Blocks     []BlockInfo
+	Trailer    int64    // end of stream and checksum in bytes, 0 for legacy files
+	Checksum   Checksum // checksum stored in the trailer
+	Size       int64    // size of encoded file in bytes
+}
+
+// layout of a block
+type BlockInfo struct {
+	Offset    int64        // position of block in encoded file
+	Size      int64        // original size declared by block header, -1 for legacy files
+	Codes     HuffmanCodes // canonical codes read from huffman table
+	TableSize int          // block header and huffman table in bytes
+	DataWidth uint64       // encoded data in bits
+	DataSize  int64        // bit width and encoded data in bytes
+}
+
+// stops readBlock before encoded data
+var errSkipData = errors.New("skip encoded data")
+
+// read header and huffman tables of encoded data, encoded data is skipped
+//
+// errors in encoded data are returned as *FormatError, the checksum is not verified
+func ReadInfo(r io.Reader) (info Info, err error) {
+	var reader *bufio.Reader = bufio.NewReader(r)
+	var size int
+	info.Header, size, err = readHeader(reader)
+	if err != nil {
+		return info, err
+	}
+	info.HeaderSize = int64(size)
+	info.Size = int64(size)
+	info.Checksum = Checksum{Algorithm: info.Header.Checksum}
+
+	var buffer []byte
+	for {
+		var block BlockInfo = BlockInfo{Offset: info.Size, Size: -1}
+
+		// check end of stream
+		if info.Header.Version == LegacyVersion {
+			_, err = reader.Peek(1)
+			if err == io.EOF {
+				return info, nil
+			}
+			if err != nil {
+				return info, err
+			}
+			if len(info.Blocks) > 0 {
+				return info, &FormatError{Offset: block.Offset, Err: fmt.Errorf("%w: %w", ErrNotHuffman, errLegacyTrailing)}
+			}
+		} else {
+			var blockHeader [blockHeaderSize]byte
+			_, err = io.ReadFull(reader, blockHeader[:])
+			if err != nil {
+				return info, &FormatError{Offset: block.Offset, Err: fmt.Errorf("%w: read block header failed: %w", ErrTruncatedData, unexpectedEOF(err))}
+			}
+			info.Size += blockHeaderSize
+			block.Size = int64(binary.BigEndian.Uint32(blockHeader[:]))
+			block.TableSize = blockHeaderSize
+			if block.Size == 0 {
+				return info, readInfoTrailer(reader, &info)
+			}
+		}
+
+		// read huffman table and bit width, skip encoded data
+		var dataSize uint64
+		var read, readTable = blockReaders(info.Header.Version)
+		buffer, err = read(reader, buffer[:0], func(tableSize int, size uint64) error {
+			dataSize = size
+			return errSkipData
+		})
+		if !errors.Is(err, errSkipData) {
+			return info, legacyError(info, &FormatError{Offset: block.Offset, Err: fmt.Errorf("%w: %w", ErrTruncatedData, err)})
+		}
+		var tableSize int = len(buffer) - 8
+		block.Codes, err = readTable(NewBitsReader(buffer[:tableSize], tableSize*8))
+		if err != nil {
+			return info, legacyError(info, &FormatError{Offset: block.Offset, Err: err})
+		}
+		block.TableSize += tableSize
+		block.DataWidth = binary.BigEndian.Uint64(buffer[tableSize:])
+		block.DataSize = 8 + int64(dataSize)
+		var skipped int64
+		skipped, err = io.CopyN(io.Discard, reader, int64(dataSize))
+		info.Size += int64(len(buffer)) + skipped
+		if err != nil {
+			return info, &FormatError{Offset: block.Offset, Err: fmt.Errorf("%w: read encoded data failed: %w", ErrTruncatedData, unexpectedEOF(err))}
+		}
+		info.Blocks = append(info.Blocks, block)
+	}
+}
+
+// read checksum after end of stream
+func readInfoTrailer(reader *bufio.Reader, info *Info) (err error) {
+	info.Trailer = blockHeaderSize
+	var hash = newChecksumHash(info.Header.Checksum)
+	if hash == nil {
+		return nil
+	}
+	info.Checksum.Value = make([]byte, hash.Size())
+	_, err = io.ReadFull(reader, info.Checksum.Value)
+	if err != nil {
+		info.Checksum.Value = nil
+		return &FormatError{Offset: info.Size, Err: fmt.Errorf("%w: read checksum failed: %w", ErrTruncatedData, unexpectedEOF(err))}
+	}
+	info.Trailer += int64(hash.Size())
+	info.Size += int64(hash.Size())
+	return nil
+}
+
+// first block of legacy file decides whether it is a huffman file, see Reader
+func legacyError(info Info, err *FormatError) error {
+	if info.Header.Version == LegacyVersion && len(info.Blocks) == 0 {
+		return &FormatError{Offset: err.Offset, Err: fmt.Errorf("%w: %w", ErrNotHuffman, err.Err)}
+	}
+	return err
+}
+
+// original size, from header or block headers, -1 if unknown
+func (info Info) OriginalSize() int64 {
+	if info.Header.Size >= 0 {
+		return info.Header.Size
+	}
+	var size int64 = 0
+	for _, block := range info.Blocks {
+		if block.Size < 0 {
+			return -1
+		}
+		size += block.Size
+	}
+	return size
+}
+
+// header, block headers, huffman tables and trailer in bytes
+func (info Info) TableSize() (size int64) {
+	size = info.HeaderSize + info.Trailer
+	for _, block := range info.Blocks {
+		size += int64(block.TableSize)
+	}
+	return size
+}
+
+// bit widths and encoded data in bytes
+func (info Info) DataSize() (size int64) {
+	for _, block := range info.Blocks {
+		size += block.DataSize
+	}
+	return size
+}
@@ -0,0 +1,76 @@
+package huffman
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+)
+
+// layout read by ReadInfo matches what was encoded
+func TestReadInfoSizes(t *testing.T) {
+	var tests = []struct {
+		size      int
+		blockSize int
+		checksum  ChecksumAlgorithm
+	}{
+		{0, 0, ChecksumCRC32},
+		{1, 0, ChecksumNone},
+		{1000, 0, ChecksumCRC32},
+		{1000, 100, ChecksumXXH64},
+		{1000, 300, ChecksumSHA256},
+		{4096, 1024, ChecksumCRC32},
+	}
+	for _, test := range tests {
+		var name string = fmt.Sprintf("%d bytes in blocks of %d, %v", test.size, test.blockSize, test.checksum)
+		var text []byte = benchmarkText(test.size)
+		var options EncodeOptions = EncodeOptions{BlockSize: test.blockSize, Checksum: test.checksum}
+		var buffer bytes.Buffer
+		encodeSize, _, err := EncodeStream(context.Background(), bytes.NewReader(text), &buffer, &options)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var data []byte = buffer.Bytes()
+
+		info, err := ReadInfo(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if info.Size != int64(len(data)) || info.TableSize()+info.DataSize() != info.Size {
+			t.Errorf("%s: size %d, tables %d and data %d, want %d", name, info.Size, info.TableSize(), info.DataSize(), len(data))
+		}
+		if info.TableSize() != int64(encodeSize.HuffmanTable) || info.DataSize() != int64(encodeSize.EncodedData) {
+			t.Errorf("%s: tables %d and data %d, encoded %d and %d", name, info.TableSize(), info.DataSize(), encodeSize.HuffmanTable, encodeSize.EncodedData)
+		}
+		if info.OriginalSize() != int64(test.size) {
+			t.Errorf("%s: original size %d", name, info.OriginalSize())
+		}
+		if !bytes.Equal(info.Checksum.Value, encodeSize.Checksum.Value) {
+			t.Errorf("%s: checksum %x, encoded %x", name, info.Checksum.Value, encodeSize.Checksum.Value)
+		}
+
+		// blocks are full except the last one, and follow each other
+		var blockSize int = test.blockSize
+		if blockSize == 0 {
+			blockSize = DefaultBlockSize
+		}
+		var blocks int = (test.size + blockSize - 1) / blockSize
+		if len(info.Blocks) != blocks {
+			t.Fatalf("%s: %d blocks, want %d", name, len(info.Blocks), blocks)
+		}
+		var offset int64 = info.HeaderSize
+		for i, block := range info.Blocks {
+			var want int = min(blockSize, test.size-i*blockSize)
+			if block.Size != int64(want) || block.Offset != offset {
+				t.Errorf("%s: block %d of %d bytes at %d, want %d at %d", name, i, block.Size, block.Offset, want, offset)
+			}
+			if block.DataSize != 8+int64(block.DataWidth+7)/8 {
+				t.Errorf("%s: block %d has %d bits in %d bytes", name, i, block.DataWidth, block.DataSize)
+			}
+			offset += int64(block.TableSize) + block.DataSize
+		}
+		if offset+info.Trailer != info.Size {
+			t.Errorf("%s: blocks end at %d, trailer of %d, size %d", name, offset, info.Trailer, info.Size)
+		}
+	}
+}
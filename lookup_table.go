@@ -0,0 +1,149 @@
+package huffman
+
+import "fmt"
+
+// number of bits resolved by the first level of lookup table
+const lookupBits = 10
+
+// max code width decoded by lookup table, wider codes fall back to readString
+const lookupMaxWidth = DefaultMaxCodeWidth
+
+// entry of lookup table
+//
+// leaf entry: width is the code width, char is the decoded character
+//
+// link entry: width is 0, subtable of 1 << subBits entries starts at sub
+//
+// width and subBits both 0: no code matches
+type lookupEntry struct {
+	char    byte
+	width   uint8
+	subBits uint8
+	sub     int32
+}
+
+// two-level lookup table for huffman decoding
+//
+// the first level is indexed by the next lookupBits bits, codes wider than
+// lookupBits are resolved by a second level table indexed by the following bits
+type lookupTable struct {
+	entries []lookupEntry
+}
+
+// build lookup table from codes
+//
+// codes must be no wider than lookupMaxWidth, return error if codes are not prefix-free
+func newLookupTable(codes HuffmanCodes) (ret *lookupTable, err error) {
+	ret = new(lookupTable)
+	ret.entries = make([]lookupEntry, 1<<lookupBits)
+
+	// max width of long codes for each first level prefix
+	var subWidths [1 << lookupBits]uint8
+	for char, code := range codes {
+		if code.Width == 0 || code.Width > lookupMaxWidth || code.Code>>code.Width != 0 {
+			return nil, fmt.Errorf("%w: invalid code of character %d", ErrCorruptTable, char)
+		}
+		if code.Width <= lookupBits {
+			continue
+		}
+		var prefix uint64 = code.Code >> (code.Width - lookupBits)
+		if code.Width > subWidths[prefix] {
+			subWidths[prefix] = code.Width
+		}
+	}
+
+	// allocate subtables
+	for prefix, width := range subWidths {
+		if width == 0 {
+			continue
+		}
+		var subBits uint8 = width - lookupBits
+		ret.entries[prefix] = lookupEntry{subBits: subBits, sub: int32(len(ret.entries))}
+		ret.entries = append(ret.entries, make([]lookupEntry, 1<<subBits)...)
+	}
+
+	// fill entries, each code fills all entries it is prefix of
+	for char, code := range codes {
+		var start, count uint64
+		if code.Width <= lookupBits {
+			start = code.Code << (lookupBits - code.Width)
+			count = 1 << (lookupBits - code.Width)
+		} else {
+			var link lookupEntry = ret.entries[code.Code>>(code.Width-lookupBits)]
+			var subWidth uint8 = code.Width - lookupBits
+			var suffix uint64 = code.Code & (1<<subWidth - 1)
+			start = uint64(link.sub) + suffix<<(link.subBits-subWidth)
+			count = 1 << (link.subBits - subWidth)
+		}
+		for i := start; i < start+count; i++ {
+			if ret.entries[i] != (lookupEntry{}) {
+				return nil, fmt.Errorf("%w: code of character %d is not prefix-free", ErrCorruptTable, char)
+			}
+			ret.entries[i] = lookupEntry{char: char, width: code.Width}
+		}
+	}
+	return ret, nil
+}
+
+// read string from reader using lookup table
+//
+// same result as readString, but resolve a whole code per table access
+func readStringWithTable(reader *BitsReader, table *lookupTable, maxLength int64) (text []byte, err error) {
+	// read data width
+	var dataWidth uint64
+	dataWidth, ok := reader.GetUint64()
+	if !ok {
+		return nil, fmt.Errorf("%w: failed to read data width", ErrTruncatedData)
+	}
+	if dataWidth > uint64(reader.width-reader.currentPointer) {
+		return nil, fmt.Errorf("%w: no enough bits", ErrTruncatedData)
+	}
+
+	// bit buffer, next bit is the highest bit
+	var data []byte = reader.data[reader.currentPointer/8:]
+	var buffer uint64 = 0
+	var bufferWidth uint = 0
+	var position int = 0
+	var skip uint = uint(reader.currentPointer % 8)
+	var left uint64 = dataWidth
+
+	text = make([]byte, 0)
+	for left > 0 {
+		// refill buffer, bits after the end of data are 0
+		for bufferWidth <= 56 && position < len(data) {
+			buffer |= uint64(data[position]) << (56 - bufferWidth)
+			position++
+			bufferWidth += 8
+		}
+		if skip > 0 {
+			buffer <<= skip
+			bufferWidth -= skip
+			skip = 0
+		}
+
+		// look up first level, then second level
+		var entry lookupEntry = table.entries[buffer>>(64-lookupBits)]
+		if entry.width == 0 && entry.subBits != 0 {
+			var index uint64 = (buffer << lookupBits) >> (64 - uint64(entry.subBits))
+			entry = table.entries[uint64(entry.sub)+index]
+		}
+		if entry.width == 0 {
+			return nil, fmt.Errorf("%w: reached nil node", ErrInvalidCode)
+		}
+		if uint64(entry.width) > left {
+			return nil, fmt.Errorf("%w: data ends inside a code", ErrInvalidCode)
+		}
+
+		// consume code
+		if int64(len(text)) == maxLength {
+			return nil, errTooLong
+		}
+		text = append(text, entry.char)
+		buffer <<= entry.width
+		bufferWidth -= uint(entry.width)
+		left -= uint64(entry.width)
+	}
+
+	reader.Seek(int(dataWidth))
+	return text, nil
+}
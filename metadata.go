@@ -0,0 +1,211 @@
+package huffman
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tags of metadata entries
+const (
+	metadataName       = 1 // base name, utf-8
+	metadataMode       = 2 // 4 bytes, unix permission bits with setuid, setgid and sticky bits
+	metadataModTime    = 3 // 8 bytes, unix time in nanoseconds
+	metadataAccessTime = 4 // 8 bytes, unix time in nanoseconds
+)
+
+// max size of metadata entries (in bytes)
+const maxMetadataSize = 1<<16 - 1
+
+// append metadata of header to buffer, nothing if header has no metadata
+//
+// format:
+//
+//	2 bytes  : size of all entries (in bytes)
+//	n group of:
+//	    1 byte   : tag
+//	    2 bytes  : size of value (in bytes)
+//	    n bytes  : value
+//
+// readers skip unknown tags, so entries can be added without a new version
+func appendMetadata(buffer []byte, header Header) (ret []byte, err error) {
+	var entries []byte
+	var appendEntry = func(tag byte, value []byte) {
+		entries = append(entries, tag)
+		entries = binary.BigEndian.AppendUint16(entries, uint16(len(value)))
+		entries = append(entries, value...)
+	}
+
+	if header.Name != "" {
+		if !validName(header.Name) {
+			return nil, fmt.Errorf("invalid file name %q in header", header.Name)
+		}
+		appendEntry(metadataName, []byte(header.Name))
+	}
+	if header.Mode != 0 {
+		appendEntry(metadataMode, binary.BigEndian.AppendUint32(nil, unixMode(header.Mode)))
+	}
+	if !header.ModTime.IsZero() {
+		appendEntry(metadataModTime, binary.BigEndian.AppendUint64(nil, uint64(header.ModTime.UnixNano())))
+	}
+	if !header.AccessTime.IsZero() {
+		appendEntry(metadataAccessTime, binary.BigEndian.AppendUint64(nil, uint64(header.AccessTime.UnixNano())))
+	}
+
+	if len(entries) == 0 {
+		return buffer, nil
+	}
+	if len(entries) > maxMetadataSize {
+		return nil, fmt.Errorf("metadata of %d bytes too large", len(entries))
+	}
+	buffer = binary.BigEndian.AppendUint16(buffer, uint16(len(entries)))
+	return append(buffer, entries...), nil
+}
+
+// read metadata from reader into header
+//
+// return size read(in bytes), see appendMetadata for the format
+func readMetadata(r *bufio.Reader, header *Header) (size int, err error) {
+	var sizeBuffer [2]byte
+	_, err = io.ReadFull(r, sizeBuffer[:])
+	if err != nil {
+		return 0, fmt.Errorf("%w: truncated metadata", ErrCorruptMetadata)
+	}
+	var entries []byte = make([]byte, binary.BigEndian.Uint16(sizeBuffer[:]))
+	_, err = io.ReadFull(r, entries)
+	if err != nil {
+		return 0, fmt.Errorf("%w: truncated metadata", ErrCorruptMetadata)
+	}
+	size = len(sizeBuffer) + len(entries)
+
+	var seen [256]bool
+	for len(entries) > 0 {
+		if len(entries) < 3 {
+			return size, fmt.Errorf("%w: truncated entry", ErrCorruptMetadata)
+		}
+		var tag byte = entries[0]
+		var length int = int(binary.BigEndian.Uint16(entries[1:3]))
+		if len(entries)-3 < length {
+			return size, fmt.Errorf("%w: truncated entry %d", ErrCorruptMetadata, tag)
+		}
+		var value []byte = entries[3 : 3+length]
+		entries = entries[3+length:]
+		if seen[tag] {
+			return size, fmt.Errorf("%w: duplicate entry %d", ErrCorruptMetadata, tag)
+		}
+		seen[tag] = true
+
+		switch tag {
+		case metadataName:
+			if !validName(string(value)) {
+				return size, fmt.Errorf("%w: invalid file name %q", ErrCorruptMetadata, value)
+			}
+			header.Name = string(value)
+		case metadataMode:
+			if length != 4 {
+				return size, fmt.Errorf("%w: invalid mode entry", ErrCorruptMetadata)
+			}
+			header.Mode = fileMode(binary.BigEndian.Uint32(value))
+		case metadataModTime, metadataAccessTime:
+			if length != 8 {
+				return size, fmt.Errorf("%w: invalid time entry", ErrCorruptMetadata)
+			}
+			var t time.Time = time.Unix(0, int64(binary.BigEndian.Uint64(value)))
+			if tag == metadataModTime {
+				header.ModTime = t
+			} else {
+				header.AccessTime = t
+			}
+		}
+	}
+	return size, nil
+}
+
+// name is a plain file name without directory
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// convert file mode to unix mode bits
+func unixMode(mode os.FileMode) (ret uint32) {
+	ret = uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		ret |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		ret |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		ret |= 0o1000
+	}
+	return ret
+}
+
+// convert unix mode bits to file mode
+func fileMode(mode uint32) (ret os.FileMode) {
+	ret = os.FileMode(mode) & os.ModePerm
+	if mode&0o4000 != 0 {
+		ret |= os.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		ret |= os.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		ret |= os.ModeSticky
+	}
+	return ret
+}
+
+// set metadata of header from file info, access time only if withAccessTime is true
+func setFileMetadata(header *Header, info os.FileInfo, withAccessTime bool) {
+	header.Name = info.Name()
+	header.Mode = info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	header.ModTime = info.ModTime()
+	if withAccessTime {
+		header.AccessTime = accessTime(info)
+	}
+}
+
+// restore mode and times of header to file
+//
+// setuid, setgid and sticky bits are restored only if specialBits is true
+func restoreFileMetadata(file *os.File, header Header, specialBits bool) (err error) {
+	var mode os.FileMode = header.Mode
+	if !specialBits {
+		mode &= os.ModePerm
+	}
+	if mode != 0 {
+		err = file.Chmod(mode)
+		if err != nil {
+			return err
+		}
+	}
+	if !header.ModTime.IsZero() || !header.AccessTime.IsZero() {
+		err = os.Chtimes(file.Name(), header.AccessTime, header.ModTime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name of decoded file
+//
+// the name stored in header, or the input name without extension if no name
+// is stored or ignoreName is true
+func decodedName(inputPath string, header Header, ignoreName bool) string {
+	if header.Name != "" && !ignoreName {
+		return header.Name
+	}
+	var base string = filepath.Base(inputPath)
+	var ext string = filepath.Ext(base)
+	if ext == "" || ext == base {
+		return base + ".out"
+	}
+	return base[:len(base)-len(ext)]
+}
@@ -0,0 +1,83 @@
+package huffman
+
+import "sort"
+
+// item of package-merge list, either a character or a package of two items
+type packageItem struct {
+	weight int
+	char   byte
+	leaf   bool
+	left   *packageItem
+	right  *packageItem
+}
+
+// get optimal code widths no longer than maxWidth with package-merge algorithm
+//
+// frequence must contain at least 2 characters and no more than 1 << maxWidth
+//
+// returned codes only have Width set, use canonicalCodes to assign codes
+func packageMerge(frequence map[byte]int, maxWidth int) (ret HuffmanCodes) {
+	// leaves sorted by frequence, then by character
+	var leaves []*packageItem = make([]*packageItem, 0, len(frequence))
+	for char, count := range frequence {
+		leaves = append(leaves, &packageItem{weight: count, char: char, leaf: true})
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		if leaves[i].weight != leaves[j].weight {
+			return leaves[i].weight < leaves[j].weight
+		}
+		return leaves[i].char < leaves[j].char
+	})
+
+	// each round packages the list and merges it with leaves again,
+	// the list after maxWidth - 1 rounds covers all widths up to maxWidth
+	var list []*packageItem = leaves
+	for level := 1; level < maxWidth; level++ {
+		var packages []*packageItem = make([]*packageItem, 0, len(list)/2)
+		for i := 0; i+1 < len(list); i += 2 {
+			packages = append(packages, &packageItem{
+				weight: list[i].weight + list[i+1].weight,
+				left:   list[i],
+				right:  list[i+1],
+			})
+		}
+		list = mergePackages(leaves, packages)
+	}
+
+	// each occurrence of a character in the first 2n - 2 items adds 1 to its width
+	ret = make(HuffmanCodes, len(frequence))
+	var stack *Stack[*packageItem] = NewStack[*packageItem]()
+	for _, item := range list[:2*len(leaves)-2] {
+		stack.Push(item)
+	}
+	for !stack.Empty() {
+		item, _ := stack.Pop()
+		if item.leaf {
+			code := ret[item.char]
+			code.Width++
+			ret[item.char] = code
+			continue
+		}
+		stack.Push(item.left)
+		stack.Push(item.right)
+	}
+	return ret
+}
+
+// merge two lists sorted by weight, leaves first when weights are equal
+func mergePackages(leaves, packages []*packageItem) (ret []*packageItem) {
+	ret = make([]*packageItem, 0, len(leaves)+len(packages))
+	var i, j int
+	for i < len(leaves) && j < len(packages) {
+		if leaves[i].weight <= packages[j].weight {
+			ret = append(ret, leaves[i])
+			i++
+		} else {
+			ret = append(ret, packages[j])
+			j++
+		}
+	}
+	ret = append(ret, leaves[i:]...)
+	ret = append(ret, packages[j:]...)
+	return ret
+}
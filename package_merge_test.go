@@ -0,0 +1,109 @@
+package huffman
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// frequence of n characters growing as Fibonacci numbers, the huffman tree has depth n - 1
+func fibonacciFrequence(n int) (frequence map[byte]int) {
+	frequence = make(map[byte]int, n)
+	var a, b int = 1, 1
+	for i := 0; i < n; i++ {
+		frequence[byte(i)] = a
+		a, b = b, a+b
+	}
+	return frequence
+}
+
+// total cost of codes, sum of frequence * width
+func codesCost(frequence map[byte]int, codes HuffmanCodes) (cost int) {
+	for char, count := range frequence {
+		cost += count * int(codes[char].Width)
+	}
+	return cost
+}
+
+// optimal cost of codes no longer than maxWidth by exhaustive search
+//
+// with frequencies in descending order, some optimal widths are ascending,
+// so only ascending widths with Kraft sum 1 are tried
+func bruteForceCost(frequence map[byte]int, maxWidth int) int {
+	var counts []int = make([]int, 0, len(frequence))
+	for _, count := range frequence {
+		counts = append(counts, count)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+
+	// left is the unused Kraft sum in units of 2^-maxWidth
+	var best int = -1
+	var search func(index int, minWidth int, left int, cost int)
+	search = func(index int, minWidth int, left int, cost int) {
+		var remaining int = len(counts) - index
+		if remaining == 0 {
+			if left == 0 && (best < 0 || cost < best) {
+				best = cost
+			}
+			return
+		}
+		for width := minWidth; width <= maxWidth; width++ {
+			var unit int = 1 << (maxWidth - width)
+			// remaining characters are no shorter, so use at most unit each
+			if unit*remaining < left {
+				break
+			}
+			if unit > left || remaining > left {
+				continue
+			}
+			search(index+1, width, left-unit, cost+counts[index]*width)
+		}
+	}
+	search(0, 1, 1<<maxWidth, 0)
+	return best
+}
+
+// codes limited by package-merge fit the limit, are complete and optimal
+func TestPackageMerge(t *testing.T) {
+	var tests = []struct {
+		characters int
+		maxWidth   int
+	}{
+		{10, 4},
+		{10, 5},
+		{10, 7},
+		{16, 6},
+		{20, 9},
+		{20, 12},
+		{30, 12},
+	}
+	for _, test := range tests {
+		var name string = fmt.Sprintf("%d characters, max width %d", test.characters, test.maxWidth)
+		var frequence map[byte]int = fibonacciFrequence(test.characters)
+		if depth := frequenceToTree(frequence).Height() - 1; depth <= test.maxWidth {
+			t.Fatalf("%s: huffman tree depth %d does not force the limit", name, depth)
+		}
+
+		codes, err := frequenceToCodes(frequence, test.maxWidth)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if width := widestCode(codes); int(width) > test.maxWidth {
+			t.Errorf("%s: widest code %d", name, width)
+		}
+		// Kraft sum exactly 1, and canonical codes decode
+		var kraft int = 0
+		for _, code := range codes {
+			kraft += 1 << (test.maxWidth - int(code.Width))
+		}
+		if kraft != 1<<test.maxWidth {
+			t.Errorf("%s: Kraft sum %d / 2^%d, want 1", name, kraft, test.maxWidth)
+		}
+		if _, err = GetHuffmanTree(codes); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if cost, want := codesCost(frequence, codes), bruteForceCost(frequence, test.maxWidth); cost != want {
+			t.Errorf("%s: cost %d, optimal %d", name, cost, want)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package huffman
 
 type Compare func(any, any) bool
 
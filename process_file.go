@@ -1,31 +1,65 @@
-package main
+package huffman
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // try to open output file, create directory if not exist
-func OpenFile(filePath string) (file *os.File, err error) {
+//
+// data is written to a temporary file in the same directory, call Commit to
+// move it to filePath, Close without Commit removes the temporary file
+func OpenFile(filePath string) (file *AtomicFile, err error) {
 	// if output directory not exist, create it
 	var dirPath string = filepath.Dir(filePath)
 	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
 		err := os.MkdirAll(dirPath, os.ModePerm)
 		if err != nil {
-			return nil, fmt.Errorf("create output directory %s failed: %v", dirPath, err.Error())
+			return nil, fmt.Errorf("create output directory %s failed: %w", dirPath, err)
 		}
 	}
 
-	// create output file
-	file, err = os.Create(filePath)
+	// create temporary file
+	file, err = CreateAtomicFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("create output file %s failed: %v", filePath, err.Error())
+		return nil, fmt.Errorf("create output file %s failed: %w", filePath, err)
 	}
 	return file, nil
 }
 
-// get all files in directory
+// check output path before encoding or decoding
+//
+// return error wrapping ErrOutputExists if output exists and force is false,
+// output is never allowed to be the input file itself
+func checkOutputPath(inputPath, outputPath string, force bool) error {
+	outputInfo, err := os.Stat(outputPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat output file %s failed: %w", outputPath, err)
+	}
+	if outputInfo.IsDir() {
+		return fmt.Errorf("output path %s is a directory", outputPath)
+	}
+	if inputInfo, err := os.Stat(inputPath); err == nil && os.SameFile(inputInfo, outputInfo) {
+		return fmt.Errorf("output file %s is the input file", outputPath)
+	}
+	if !force {
+		return fmt.Errorf("%w: %s", ErrOutputExists, outputPath)
+	}
+	return nil
+}
+
+// file is skipped in batch mode instead of reported as error
+func isSkipped(err error) bool {
+	return errors.Is(err, ErrOutputExists)
+}
+
+// get all files in directory in lexical order, see filepath.Walk
 func GetFilesInDir(dirPath string) (filePaths []string, batchErrors []BatchError, err error) {
 	filePaths = make([]string, 0)
 	// walk through directory
@@ -41,24 +75,121 @@ func GetFilesInDir(dirPath string) (filePaths []string, batchErrors []BatchError
 		return nil
 	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("walk through directory %s failed: %v", dirPath, err.Error())
+		return nil, nil, fmt.Errorf("walk through directory %s failed: %w", dirPath, err)
 	}
 	return filePaths, batchErrors, nil
 }
 
 // get output paths for input files
-// replace extension with given extension
-func GetOutputPaths(inputPaths []string, outputDir string, extension string) (outputPaths []string, errors []BatchError) {
-	outputPaths = make([]string, 0)
+//
+// output files mirror the directory structure of input files under inputDir,
+// and extension of each file is replaced with given extension
+//
+// files not under inputDir or with the same output path as another file are
+// reported as errors and not returned in validPaths, files whose output path is
+// the file itself or another input file are reported with ErrOutputExists, so
+// a second run in place skips them instead of reporting collisions
+func GetOutputPaths(inputDir string, inputPaths []string, outputDir string, extension string) (validPaths []string, outputPaths []string, errors []BatchError) {
+	validPaths = make([]string, 0, len(inputPaths))
+	outputPaths = make([]string, 0, len(inputPaths))
 	for _, inputPath := range inputPaths {
+		relPath, err := relativePath(inputDir, inputPath)
+		if err != nil {
+			errors = append(errors, BatchError{Path: inputPath, Err: err})
+			continue
+		}
+
 		// replace extension with given extension
-		var base string = filepath.Base(inputPath)
-		var ext string = filepath.Ext(base)
-		if ext != "" {
-			base = base[:len(base)-len(ext)]
+		var ext string = filepath.Ext(relPath)
+		if ext != "" && ext != filepath.Base(relPath) {
+			relPath = relPath[:len(relPath)-len(ext)]
+		}
+		validPaths = append(validPaths, inputPath)
+		outputPaths = append(outputPaths, filepath.Join(outputDir, relPath+"."+extension))
+	}
+
+	var inputErrors, collisionErrors []BatchError
+	validPaths, outputPaths, inputErrors = removeInputOutputs(validPaths, outputPaths)
+	validPaths, outputPaths, collisionErrors = removeCollisions(validPaths, outputPaths)
+	errors = append(errors, inputErrors...)
+	return validPaths, outputPaths, append(errors, collisionErrors...)
+}
+
+// remove files whose output path is an input path, e.g. output of a previous run in place
+//
+// files written to themselves are removed first, then files written to one of
+// the remaining inputs, each reported with ErrOutputExists
+func removeInputOutputs(inputPaths []string, outputPaths []string) (validPaths []string, validOutputPaths []string, errors []BatchError) {
+	var remaining map[string]bool = make(map[string]bool)
+	for i, inputPath := range inputPaths {
+		if outputPaths[i] == inputPath {
+			errors = append(errors, BatchError{Path: inputPath, Err: fmt.Errorf("%w: %s is the input file itself", ErrOutputExists, inputPath)})
+			continue
+		}
+		remaining[inputPath] = true
+	}
+
+	validPaths = make([]string, 0, len(inputPaths))
+	validOutputPaths = make([]string, 0, len(outputPaths))
+	for i, inputPath := range inputPaths {
+		if !remaining[inputPath] {
+			continue
+		}
+		if remaining[outputPaths[i]] {
+			errors = append(errors, BatchError{Path: inputPath, Err: fmt.Errorf("%w: %s is also an input file", ErrOutputExists, outputPaths[i])})
+			continue
+		}
+		validPaths = append(validPaths, inputPath)
+		validOutputPaths = append(validOutputPaths, outputPaths[i])
+	}
+	return validPaths, validOutputPaths, errors
+}
+
+// path of input file relative to input directory
+//
+// return the base name if inputDir is the file itself
+func relativePath(inputDir, inputPath string) (relPath string, err error) {
+	relPath, err = filepath.Rel(inputDir, inputPath)
+	if err != nil {
+		return "", err
+	}
+	if relPath == "." {
+		return filepath.Base(inputPath), nil
+	}
+	if relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not in directory %s", inputPath, inputDir)
+	}
+	return relPath, nil
+}
+
+// remove input files sharing an output path, each of them is reported as error
+//
+// order of remaining files is kept
+func removeCollisions(inputPaths []string, outputPaths []string) (validPaths []string, validOutputPaths []string, errors []BatchError) {
+	var inputsOfOutput map[string][]string = make(map[string][]string)
+	for i, outputPath := range outputPaths {
+		inputsOfOutput[outputPath] = append(inputsOfOutput[outputPath], inputPaths[i])
+	}
+
+	validPaths = make([]string, 0, len(inputPaths))
+	validOutputPaths = make([]string, 0, len(outputPaths))
+	for i, outputPath := range outputPaths {
+		var inputs []string = inputsOfOutput[outputPath]
+		if len(inputs) == 1 {
+			validPaths = append(validPaths, inputPaths[i])
+			validOutputPaths = append(validOutputPaths, outputPath)
+			continue
+		}
+		var others []string = make([]string, 0, len(inputs)-1)
+		for _, input := range inputs {
+			if input != inputPaths[i] {
+				others = append(others, input)
+			}
 		}
-		var outputPath string = filepath.Join(outputDir, base+"."+extension)
-		outputPaths = append(outputPaths, outputPath)
+		errors = append(errors, BatchError{
+			Path: inputPaths[i],
+			Err:  fmt.Errorf("%w: %s is also the output of %s", ErrOutputCollision, outputPath, strings.Join(others, ", ")),
+		})
 	}
-	return outputPaths, errors
+	return validPaths, validOutputPaths, errors
 }
@@ -0,0 +1,217 @@
+package huffman
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// write files of a test directory, names may contain subdirectories
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		var path string = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// existing output is kept unless Force is set
+func TestOutputExists(t *testing.T) {
+	var dir string = t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.txt": "hello, huffman", "a.bin": "existing"})
+	var input string = filepath.Join(dir, "a.txt")
+	var output string = filepath.Join(dir, "a.bin")
+
+	_, _, err := Encode(input, output)
+	if !errors.Is(err, ErrOutputExists) {
+		t.Fatalf("encode got %v, want ErrOutputExists", err)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "existing" {
+		t.Fatalf("existing output changed to %q", data)
+	}
+
+	_, _, err = EncodeWithOptions(input, output, &EncodeOptions{Force: true})
+	if err != nil {
+		t.Fatalf("encode with Force failed: %v", err)
+	}
+	var decoded string = filepath.Join(dir, "a.out")
+	if _, _, err = Decode(output, decoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if data, _ := os.ReadFile(decoded); string(data) != "hello, huffman" {
+		t.Fatalf("decoded %q", data)
+	}
+	_, _, err = Decode(output, decoded)
+	if !errors.Is(err, ErrOutputExists) {
+		t.Fatalf("decode got %v, want ErrOutputExists", err)
+	}
+}
+
+// files with existing output are reported as skipped in batch mode, not as errors
+func TestBatchSkipped(t *testing.T) {
+	var dir string = t.TempDir()
+	var input string = filepath.Join(dir, "input")
+	var output string = filepath.Join(dir, "output")
+	writeTestFiles(t, input, map[string]string{"a.txt": "aaaa", "sub/b.txt": "abab"})
+
+	result, err := BatchEncode(input, output)
+	if err != nil || result.SuccessCount != 2 || len(result.Errors) != 0 || len(result.Skipped) != 0 {
+		t.Fatalf("first batch: %d succeeded, errors %v, skipped %v, %v", result.SuccessCount, result.Errors, result.Skipped, err)
+	}
+	result, err = BatchEncode(input, output)
+	if err != nil || result.SuccessCount != 0 || len(result.Errors) != 0 || len(result.Skipped) != 2 {
+		t.Fatalf("second batch: %d succeeded, errors %v, skipped %v, %v", result.SuccessCount, result.Errors, result.Skipped, err)
+	}
+	for _, skipped := range result.Skipped {
+		if !errors.Is(skipped.Err, ErrOutputExists) {
+			t.Errorf("%s skipped with %v, want ErrOutputExists", skipped.Path, skipped.Err)
+		}
+	}
+	result, err = BatchEncodeWithOptions(input, output, &EncodeOptions{Force: true})
+	if err != nil || result.SuccessCount != 2 || len(result.Skipped) != 0 {
+		t.Fatalf("batch with Force: %d succeeded, skipped %v, %v", result.SuccessCount, result.Skipped, err)
+	}
+
+	var decoded string = filepath.Join(dir, "decoded")
+	decodeResult, err := BatchDecode(output, decoded)
+	if err != nil || decodeResult.SuccessCount != 2 {
+		t.Fatalf("batch decode: %d succeeded, errors %v, %v", decodeResult.SuccessCount, decodeResult.Errors, err)
+	}
+	decodeResult, err = BatchDecode(output, decoded)
+	if err != nil || decodeResult.SuccessCount != 0 || len(decodeResult.Errors) != 0 || len(decodeResult.Skipped) != 2 {
+		t.Fatalf("second batch decode: %d succeeded, errors %v, skipped %v, %v", decodeResult.SuccessCount, decodeResult.Errors, decodeResult.Skipped, err)
+	}
+}
+
+// input is removed only after verified processing
+func TestRemoveInput(t *testing.T) {
+	var dir string = t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.txt": "hello, huffman", "b.txt": "no checksum"})
+	var exists = func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	// checksum verifies decoded data
+	var input string = filepath.Join(dir, "a.txt")
+	var encoded string = filepath.Join(dir, "a.bin")
+	if _, _, err := EncodeWithOptions(input, encoded, &EncodeOptions{RemoveInput: true}); err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if exists(input) {
+		t.Fatal("input kept after encoding")
+	}
+	if _, _, err := DecodeWithOptions(encoded, input, &DecodeOptions{RemoveInput: true}); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if exists(encoded) {
+		t.Fatal("input kept after decoding")
+	}
+	if data, _ := os.ReadFile(input); string(data) != "hello, huffman" {
+		t.Fatalf("decoded %q", data)
+	}
+
+	// without checksum, and legacy files, input is refused to be removed
+	input = filepath.Join(dir, "b.txt")
+	encoded = filepath.Join(dir, "b.bin")
+	if _, _, err := EncodeWithOptions(input, encoded, &EncodeOptions{Checksum: ChecksumNone}); err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	legacy, original := readLegacyFixture(t, "text")
+	var legacyPath string = filepath.Join(dir, "legacy.bin")
+	if err := os.WriteFile(legacyPath, legacy, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{encoded, legacyPath} {
+		var output string = path + ".out"
+		_, _, err := DecodeWithOptions(path, output, &DecodeOptions{RemoveInput: true})
+		if !errors.Is(err, ErrNoChecksum) {
+			t.Errorf("%s: got %v, want ErrNoChecksum", path, err)
+		}
+		if !exists(path) || exists(output) {
+			t.Errorf("%s: input removed or output written", path)
+		}
+	}
+	if _, _, err := Decode(legacyPath, filepath.Join(dir, "legacy.txt")); err != nil {
+		t.Fatalf("decode legacy file failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "legacy.txt")); !bytes.Equal(data, original) {
+		t.Fatal("legacy file decoded to different data")
+	}
+}
+
+// setuid, setgid and sticky bits are restored only when asked for
+func TestRestoreSpecialBits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix permission bits")
+	}
+	var dir string = t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"a.txt": "setuid"})
+	var input string = filepath.Join(dir, "a.txt")
+	if err := os.Chmod(input, 0o755|os.ModeSetuid); err != nil {
+		t.Skipf("set setuid bit failed: %v", err)
+	}
+	var encoded string = filepath.Join(dir, "a.bin")
+	if _, _, err := Encode(input, encoded); err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	for _, special := range []bool{false, true} {
+		var output string = filepath.Join(dir, fmt.Sprintf("a-%v.txt", special))
+		if _, _, err := DecodeWithOptions(encoded, output, &DecodeOptions{SpecialBits: special}); err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		info, err := os.Stat(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want os.FileMode = 0o755
+		if special {
+			want |= os.ModeSetuid
+		}
+		if info.Mode()&(os.ModePerm|os.ModeSetuid) != want {
+			t.Errorf("SpecialBits %v: mode %v, want %v", special, info.Mode(), want)
+		}
+	}
+}
+
+// a second batch in place skips outputs of the first run instead of reporting collisions
+func TestBatchInPlace(t *testing.T) {
+	var dir string = t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"x.md": "markdown", "sub/y.txt": "text"})
+
+	result, err := BatchEncode(dir, dir)
+	if err != nil || result.SuccessCount != 2 || len(result.Errors) != 0 {
+		t.Fatalf("first batch: %d succeeded, errors %v, %v", result.SuccessCount, result.Errors, err)
+	}
+	for _, options := range []*EncodeOptions{nil, {Force: true}} {
+		result, err = BatchEncodeWithOptions(dir, dir, options)
+		var succeeded int = 0
+		if options != nil {
+			succeeded = 2 // x.md and y.txt overwrite their outputs
+		}
+		if err != nil || result.SuccessCount != succeeded || len(result.Errors) != 0 || len(result.Skipped) != 4-succeeded {
+			t.Fatalf("batch with options %+v: %d succeeded, errors %v, skipped %v, %v", options, result.SuccessCount, result.Errors, result.Skipped, err)
+		}
+		for _, skipped := range result.Skipped {
+			if !errors.Is(skipped.Err, ErrOutputExists) {
+				t.Errorf("%s skipped with %v, want ErrOutputExists", skipped.Path, skipped.Err)
+			}
+		}
+	}
+
+	// output of a.txt is an input file written elsewhere
+	var inputs []string = []string{filepath.Join("in", "a.txt"), filepath.Join("in", "out", "a.bin")}
+	validPaths, _, batchErrors := GetOutputPaths("in", inputs, filepath.Join("in", "out"), "bin")
+	if len(validPaths) != 1 || validPaths[0] != inputs[1] || len(batchErrors) != 1 || !errors.Is(batchErrors[0].Err, ErrOutputExists) {
+		t.Fatalf("valid paths %v, errors %v", validPaths, batchErrors)
+	}
+}
@@ -0,0 +1,101 @@
+package huffman
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// type of progress event
+type ProgressEventType int
+
+const (
+	ProgressFileStarted  ProgressEventType = iota // file is opened, Total is known
+	ProgressBytes                                 // Bytes of input file are processed
+	ProgressFileFinished                          // file is done, Err is nil on success
+)
+
+func (eventType ProgressEventType) String() string {
+	switch eventType {
+	case ProgressFileStarted:
+		return "started"
+	case ProgressBytes:
+		return "progress"
+	case ProgressFileFinished:
+		return "finished"
+	}
+	return "unknown"
+}
+
+// progress of encoding or decoding a file
+type ProgressEvent struct {
+	Type       ProgressEventType
+	Path       string // input file
+	OutputPath string // output file, empty if not known yet
+	Bytes      int64  // input bytes processed
+	Total      int64  // size of input file in bytes, -1 if unknown
+	Err        error  // error of finished file
+
+	// set when file is finished
+	Time       time.Duration // time spent on the file
+	EncodeSize EncodeSize    // sizes of encoded file, zero when decoding or on error
+	DecodeSize DecodeSize    // sizes of decoded file, zero when encoding or on error
+}
+
+// callback receiving progress events
+//
+// batch functions never call it concurrently
+type ProgressFunc func(event ProgressEvent)
+
+// report event if progress is not nil
+func (progress ProgressFunc) report(event ProgressEvent) {
+	if progress != nil {
+		progress(event)
+	}
+}
+
+// wrap progress so that it is called by one goroutine at a time
+func (progress ProgressFunc) serialized() ProgressFunc {
+	if progress == nil {
+		return nil
+	}
+	var mu sync.Mutex
+	return func(event ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress(event)
+	}
+}
+
+// reader stopping when context is done and reporting bytes read
+type progressReader struct {
+	ctx      context.Context
+	r        io.Reader
+	progress ProgressFunc
+	event    ProgressEvent
+}
+
+// create a new reader reading r for event.Path
+func newProgressReader(ctx context.Context, r io.Reader, progress ProgressFunc, event ProgressEvent) (ret *progressReader) {
+	ret = new(progressReader)
+	ret.ctx = ctx
+	ret.r = r
+	ret.progress = progress
+	ret.event = event
+	ret.event.Type = ProgressBytes
+	return ret
+}
+
+func (reader *progressReader) Read(p []byte) (n int, err error) {
+	err = reader.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	n, err = reader.r.Read(p)
+	if n > 0 {
+		reader.event.Bytes += int64(n)
+		reader.progress.report(reader.event)
+	}
+	return n, err
+}
@@ -0,0 +1,139 @@
+package huffman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// context canceled while a file is processed stops it without output
+func TestCancelFile(t *testing.T) {
+	var dir string = t.TempDir()
+	var input string = filepath.Join(dir, "input.txt")
+	var encoded string = filepath.Join(dir, "input.bin")
+	if err := os.WriteFile(input, benchmarkText(256<<10), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := EncodeWithOptions(input, encoded, &EncodeOptions{BlockSize: 4 << 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	// cancel on the first bytes read
+	var cancelOnProgress = func() (ctx context.Context, progress ProgressFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		return ctx, func(event ProgressEvent) {
+			if event.Type == ProgressBytes {
+				cancel()
+			}
+		}
+	}
+	var output string = filepath.Join(dir, "output")
+	ctx, progress := cancelOnProgress()
+	_, _, err := EncodeContext(ctx, input, output, &EncodeOptions{BlockSize: 4 << 10, Progress: progress})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("encode got %v, want context.Canceled", err)
+	}
+	ctx, progress = cancelOnProgress()
+	_, _, err = DecodeContext(ctx, encoded, output, &DecodeOptions{Progress: progress})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("decode got %v, want context.Canceled", err)
+	}
+	if names := dirNames(t, dir); len(names) != 2 {
+		t.Errorf("files left: %v", names)
+	}
+}
+
+// progress of a file starts, grows monotonically to the input size and finishes
+func TestProgressEvents(t *testing.T) {
+	var dir string = t.TempDir()
+	var input string = filepath.Join(dir, "input.txt")
+	var encoded string = filepath.Join(dir, "input.bin")
+	if err := os.WriteFile(input, benchmarkText(200<<10), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []ProgressEvent
+	var record ProgressFunc = func(event ProgressEvent) {
+		events = append(events, event)
+	}
+	var check = func(name string, path string) {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) < 3 || events[0].Type != ProgressFileStarted || events[len(events)-1].Type != ProgressFileFinished {
+			t.Fatalf("%s: %d events, want started, progress and finished", name, len(events))
+		}
+		var bytes int64 = 0
+		for _, event := range events {
+			if event.Path != path || event.Total != info.Size() {
+				t.Fatalf("%s: event of %s with total %d, want %s with %d", name, event.Path, event.Total, path, info.Size())
+			}
+			if event.Bytes < bytes {
+				t.Fatalf("%s: bytes went back from %d to %d", name, bytes, event.Bytes)
+			}
+			bytes = event.Bytes
+		}
+		var last ProgressEvent = events[len(events)-1]
+		if last.Err != nil || last.Bytes != info.Size() {
+			t.Fatalf("%s: finished with %d of %d bytes, %v", name, last.Bytes, info.Size(), last.Err)
+		}
+		events = nil
+	}
+
+	if _, _, err := EncodeWithOptions(input, encoded, &EncodeOptions{BlockSize: 16 << 10, Progress: record}); err != nil {
+		t.Fatal(err)
+	}
+	check("encode", input)
+	if _, _, err := DecodeWithOptions(encoded, filepath.Join(dir, "decoded"), &DecodeOptions{Progress: record}); err != nil {
+		t.Fatal(err)
+	}
+	check("decode", encoded)
+}
+
+// batch stops starting files once its context is canceled
+func TestCancelBatch(t *testing.T) {
+	var dir string = t.TempDir()
+	var input string = filepath.Join(dir, "input")
+	var files map[string]string = make(map[string]string)
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("%02d.txt", i)] = string(benchmarkText(1 << 10))
+	}
+	writeTestFiles(t, input, files)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var started, startedAfterCancel int = 0, 0
+	var options EncodeOptions = EncodeOptions{Jobs: 2}
+	options.Progress = func(event ProgressEvent) {
+		if event.Type != ProgressFileStarted {
+			return
+		}
+		if ctx.Err() != nil {
+			startedAfterCancel++
+		}
+		started++
+		if started == 3 {
+			cancel()
+		}
+	}
+	result, err := BatchEncodeContext(ctx, input, filepath.Join(dir, "output"), &options)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("batch got %v, want context.Canceled", err)
+	}
+	if startedAfterCancel != 0 {
+		t.Errorf("%d files started after cancel", startedAfterCancel)
+	}
+	if result.SuccessCount >= result.TotalCount || result.SuccessCount+len(result.Errors) != result.TotalCount {
+		t.Errorf("%d of %d files succeeded, %d errors", result.SuccessCount, result.TotalCount, len(result.Errors))
+	}
+	for _, batchErr := range result.Errors {
+		if !errors.Is(batchErr.Err, context.Canceled) {
+			t.Errorf("%s: got %v, want context.Canceled", batchErr.Path, batchErr.Err)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package huffman
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/bits"
+)
+
+// options of decoding, limits are 0 for no limit
+//
+// limits are checked before allocating memory, exceeding a limit
+// returns a *LimitError
+//
+// Force, RemoveInput, IgnoreName and SpecialBits are used by Decode and BatchDecode only,
+// Jobs and BatchMemory by BatchDecode and BatchVerify only, Progress by Decode,
+// Verify and their batch variants
+type DecodeOptions struct {
+	MaxOutputSize int64        // max decoded size in bytes
+	MaxTableSize  int          // max size of a huffman table in bytes
+	MaxMemory     int64        // max memory of a block in bytes, encoded and decoded data
+	Force         bool         // overwrite existing output files
+	RemoveInput   bool         // remove input file after successful decoding, files without checksum are refused
+	IgnoreName    bool         // name output in a directory after input file, not the stored name
+	SpecialBits   bool         // restore stored setuid, setgid and sticky bits, only permission bits by default
+	Jobs          int          // files decoded at the same time in batch mode, GOMAXPROCS if 0
+	BatchMemory   int64        // estimated memory of files decoded at the same time in batch mode, 0 for no limit
+	Progress      ProgressFunc // called with progress of each file, may be nil
+}
+
+// Reader decompresses data read from the underlying reader
+//
+// blocks are read and decoded one at a time, so memory use is bounded
+// by the block size used by the writer
+//
+// files without header are read as LegacyVersion
+type Reader struct {
+	options     DecodeOptions
+	r           *bufio.Reader
+	header      Header
+	headerRead  bool
+	headerErr   error
+	hash        hash.Hash
+	checksum    Checksum
+	blocks      int
+	inputOffset int64  // encoded bytes consumed
+	decoded     int64  // decoded bytes of all blocks
+	block       []byte // raw bytes of current block
+	text        []byte // decoded data of current block
+	offset      int    // read position in text
+	err         error
+}
+
+// create a new reader decompressing data from r
+func NewReader(r io.Reader) (ret *Reader) {
+	return NewReaderWithOptions(r, nil)
+}
+
+// create a new reader with limits, options may be nil for no limit
+func NewReaderWithOptions(r io.Reader, options *DecodeOptions) (ret *Reader) {
+	ret = new(Reader)
+	if options != nil {
+		ret.options = *options
+	}
+	ret.Reset(r)
+	return ret
+}
+
+// discard the reader state and read from r, keep options
+func (reader *Reader) Reset(r io.Reader) {
+	if reader.r == nil {
+		reader.r = bufio.NewReader(r)
+	} else {
+		reader.r.Reset(r)
+	}
+	reader.header = Header{}
+	reader.headerRead = false
+	reader.headerErr = nil
+	reader.hash = nil
+	reader.checksum = Checksum{}
+	reader.blocks = 0
+	reader.inputOffset = 0
+	reader.decoded = 0
+	reader.block = reader.block[:0]
+	reader.text = nil
+	reader.offset = 0
+	reader.err = nil
+}
+
+// read header of the stream if not read yet
+//
+// return ErrNotHuffman if the stream is not a huffman encoded file
+func (reader *Reader) Header() (header Header, err error) {
+	if !reader.headerRead {
+		reader.headerRead = true
+		var size int
+		reader.header, size, reader.headerErr = readHeader(reader.r)
+		if reader.headerErr != nil {
+			reader.err = reader.headerErr
+		}
+		reader.inputOffset = int64(size)
+		reader.hash = newChecksumHash(reader.header.Checksum)
+		reader.checksum = Checksum{Algorithm: reader.header.Checksum}
+
+		// reject large files before decoding anything
+		if reader.headerErr == nil && reader.options.MaxOutputSize > 0 && reader.header.Size > reader.options.MaxOutputSize {
+			reader.headerErr = &LimitError{Limit: "MaxOutputSize", Value: reader.header.Size, Max: reader.options.MaxOutputSize}
+			reader.err = reader.headerErr
+		}
+	}
+	return reader.header, reader.headerErr
+}
+
+// read decompressed data, return io.EOF at the end of stream
+func (reader *Reader) Read(p []byte) (n int, err error) {
+	_, err = reader.Header()
+	if err != nil {
+		return 0, err
+	}
+
+	for n < len(p) {
+		// current block consumed, decode next one
+		if reader.offset == len(reader.text) {
+			if reader.err != nil {
+				break
+			}
+			reader.err = reader.nextBlock()
+			continue
+		}
+
+		var copied int = copy(p[n:], reader.text[reader.offset:])
+		reader.offset += copied
+		n += copied
+	}
+
+	if n > 0 {
+		return n, nil
+	}
+	return 0, reader.err
+}
+
+// data after the single block of a legacy file, the file is not a legacy huffman file
+var errLegacyTrailing = errors.New("data after the block of a legacy file")
+
+// decode next block, reject legacy files with invalid first block
+func (reader *Reader) nextBlock() (err error) {
+	err = reader.decodeBlock()
+	// first block of legacy file decides whether it is a huffman file
+	var formatErr *FormatError
+	if reader.header.Version == LegacyVersion && reader.blocks == 0 && errors.As(err, &formatErr) {
+		return &FormatError{Offset: formatErr.Offset, Err: fmt.Errorf("%w: %w", ErrNotHuffman, formatErr.Err)}
+	}
+	return err
+}
+
+// read and decode next block, legacy files have a single block
+//
+// errors in encoded data are returned as *FormatError
+func (reader *Reader) decodeBlock() (err error) {
+	var blockOffset int64 = reader.inputOffset
+	reader.text = reader.text[:0]
+	reader.offset = 0
+
+	// check end of stream
+	var blockSize int64 = -1
+	if reader.header.Version == LegacyVersion {
+		_, err = reader.r.Peek(1)
+		if err != nil {
+			return err
+		}
+		if reader.blocks > 0 {
+			return &FormatError{Offset: blockOffset, Err: fmt.Errorf("%w: %w", ErrNotHuffman, errLegacyTrailing)}
+		}
+	} else {
+		var blockHeader [blockHeaderSize]byte
+		_, err = io.ReadFull(reader.r, blockHeader[:])
+		if err != nil {
+			return reader.formatError(blockOffset, fmt.Errorf("%w: read block header failed: %w", ErrTruncatedData, unexpectedEOF(err)))
+		}
+		reader.inputOffset += blockHeaderSize
+		blockSize = int64(binary.BigEndian.Uint32(blockHeader[:]))
+		if blockSize == 0 {
+			return reader.endOfStream(blockOffset)
+		}
+		err = reader.checkLimit("MaxOutputSize", reader.decoded+blockSize, reader.options.MaxOutputSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check table and memory limits before reading encoded data
+	var check = func(tableSize int, dataSize uint64) error {
+		err := reader.checkLimit("MaxTableSize", int64(tableSize), int64(reader.options.MaxTableSize))
+		if err != nil {
+			return err
+		}
+		var memory uint64 = uint64(tableSize) + 8 + dataSize
+		if blockSize > 0 {
+			memory += uint64(blockSize)
+		}
+		if reader.options.MaxMemory > 0 && memory > uint64(reader.options.MaxMemory) {
+			return &LimitError{Limit: "MaxMemory", Value: int64(min(memory, 1<<63-1)), Max: reader.options.MaxMemory}
+		}
+		return nil
+	}
+	var read, readTable = blockReaders(reader.header.Version)
+	reader.block, err = read(reader.r, reader.block[:0], check)
+	reader.inputOffset += int64(len(reader.block))
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return err
+	}
+	if errors.Is(err, ErrCorruptTable) {
+		return &FormatError{Offset: blockOffset, Err: err}
+	}
+	if err != nil {
+		return reader.formatError(blockOffset, fmt.Errorf("%w: %w", ErrTruncatedData, err))
+	}
+
+	// max decoded length of block
+	var maxLength int64 = blockSize
+	var limitName string
+	if blockSize < 0 {
+		maxLength, limitName = reader.legacyMaxLength()
+	}
+	var bitsReader *BitsReader = NewBitsReader(reader.block, len(reader.block)*8)
+
+	// read huffman table
+	var codes HuffmanCodes
+	codes, err = readTable(bitsReader)
+	if err != nil {
+		return &FormatError{Offset: blockOffset, Err: err}
+	}
+
+	// read string with lookup table, or with huffman tree for wide codes
+	if widestCode(codes) <= lookupMaxWidth {
+		var table *lookupTable
+		table, err = newLookupTable(codes)
+		if err != nil {
+			return &FormatError{Offset: blockOffset, Err: err}
+		}
+		reader.text, err = readStringWithTable(bitsReader, table, maxLength)
+	} else {
+		var tree *Tree[byte]
+		tree, err = GetHuffmanTree(codes)
+		if err != nil {
+			return &FormatError{Offset: blockOffset, Err: err}
+		}
+		reader.text, err = readString(bitsReader, tree, maxLength)
+	}
+	if errors.Is(err, errTooLong) {
+		if limitName == "MaxOutputSize" {
+			return &LimitError{Limit: limitName, Value: reader.decoded + maxLength + 1, Max: reader.options.MaxOutputSize}
+		}
+		if limitName == "MaxMemory" {
+			return &LimitError{Limit: limitName, Value: int64(len(reader.block)) + maxLength + 1, Max: reader.options.MaxMemory}
+		}
+		return &FormatError{Offset: blockOffset, Err: fmt.Errorf("%w: decoded more than %d bytes declared by block header", ErrSizeMismatch, blockSize)}
+	}
+	if err != nil {
+		return &FormatError{Offset: blockOffset, Err: err}
+	}
+	if blockSize >= 0 && int64(len(reader.text)) != blockSize {
+		return &FormatError{Offset: blockOffset, Err: fmt.Errorf("%w: decoded %d bytes, block header declares %d bytes", ErrSizeMismatch, len(reader.text), blockSize)}
+	}
+	reader.blocks++
+	reader.decoded += int64(len(reader.text))
+	if reader.hash != nil {
+		reader.hash.Write(reader.text)
+	}
+	return nil
+}
+
+// return *LimitError if value exceeds max, max 0 for no limit
+func (reader *Reader) checkLimit(limit string, value int64, max int64) error {
+	if max > 0 && value > max {
+		return &LimitError{Limit: limit, Value: value, Max: max}
+	}
+	return nil
+}
+
+// max decoded length of legacy block without block size, -1 for no limit
+//
+// return the length and name of the limit deciding it
+func (reader *Reader) legacyMaxLength() (maxLength int64, limit string) {
+	maxLength = -1
+	if reader.options.MaxOutputSize > 0 {
+		maxLength = max(reader.options.MaxOutputSize-reader.decoded, 0)
+		limit = "MaxOutputSize"
+	}
+	if reader.options.MaxMemory > 0 {
+		var left int64 = max(reader.options.MaxMemory-int64(len(reader.block)), 0)
+		if maxLength < 0 || left < maxLength {
+			maxLength = left
+			limit = "MaxMemory"
+		}
+	}
+	return maxLength, limit
+}
+
+// wrap error of encoded data as *FormatError, keep other I/O errors
+func (reader *Reader) formatError(offset int64, err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return &FormatError{Offset: offset, Err: err}
+	}
+	return err
+}
+
+// check decoded size and checksum at the end of stream
+func (reader *Reader) endOfStream(offset int64) (err error) {
+	if reader.header.Size >= 0 && reader.decoded != reader.header.Size {
+		return &FormatError{Offset: offset, Err: fmt.Errorf("%w: decoded %d bytes, header declares %d bytes", ErrSizeMismatch, reader.decoded, reader.header.Size)}
+	}
+
+	if reader.hash != nil {
+		var stored []byte = make([]byte, reader.hash.Size())
+		_, err = io.ReadFull(reader.r, stored)
+		if err != nil {
+			return reader.formatError(reader.inputOffset, fmt.Errorf("%w: read checksum failed: %w", ErrTruncatedData, unexpectedEOF(err)))
+		}
+		var computed []byte = reader.hash.Sum(nil)
+		if !bytes.Equal(stored, computed) {
+			return &FormatError{Offset: reader.inputOffset, Err: fmt.Errorf("%w: %v stored %x, computed %x", ErrChecksumMismatch, reader.header.Checksum, stored, computed)}
+		}
+		reader.inputOffset += int64(len(stored))
+		reader.checksum.Value = computed
+	}
+	return io.EOF
+}
+
+// checksum of decoded data, valid after the whole stream is read
+func (reader *Reader) Checksum() Checksum {
+	return reader.checksum
+}
+
+// block and huffman table readers of format version
+//
+// legacy files use the headerless layout of the first release, see readLegacyHuffmanTable
+func blockReaders(version int) (read func(*bufio.Reader, []byte, func(int, uint64) error) ([]byte, error), readTable func(*BitsReader) (HuffmanCodes, error)) {
+	if version == LegacyVersion {
+		return readLegacyBlock, readLegacyHuffmanTable
+	}
+	return readBlock, readHuffmanTable
+}
+
+// read raw bytes of a block into buffer
+//
+// only the framing is checked here, content is checked while decoding
+//
+// check is called with table size and encoded data size (in bytes)
+// before reading encoded data, may be nil
+func readBlock(r *bufio.Reader, buffer []byte, check func(tableSize int, dataSize uint64) error) (ret []byte, err error) {
+	var start int = len(buffer)
+
+	// read huffman table, see writeHuffmanTable for the format
+	var tableType byte
+	tableType, err = r.ReadByte()
+	if err != nil {
+		return buffer, unexpectedEOF(err)
+	}
+	buffer = append(buffer, tableType)
+	switch tableType {
+	case tableList:
+		buffer, err = appendFull(r, buffer, 1)
+		if err != nil {
+			return buffer, err
+		}
+		var count = uint64(buffer[len(buffer)-1]) + 1
+		buffer, err = appendFull(r, buffer, 2*count)
+	case tableBitmap:
+		buffer, err = appendFull(r, buffer, tableBitmapSize)
+		if err != nil {
+			return buffer, err
+		}
+		var count uint64 = 0
+		for _, b := range buffer[len(buffer)-tableBitmapSize:] {
+			count += uint64(bits.OnesCount8(b))
+		}
+		buffer, err = appendFull(r, buffer, count)
+	case tableEmpty:
+	default:
+		return buffer, fmt.Errorf("%w: unknown table type %d", ErrCorruptTable, tableType)
+	}
+	if err != nil {
+		return buffer, err
+	}
+	return appendData(r, buffer, len(buffer)-start, check)
+}
+
+// read raw bytes of a legacy block into buffer, see readBlock
+//
+// the table is read up to its end marker, see readLegacyHuffmanTable
+func readLegacyBlock(r *bufio.Reader, buffer []byte, check func(tableSize int, dataSize uint64) error) (ret []byte, err error) {
+	var start int = len(buffer)
+	for codes := 0; ; codes++ {
+		var codeWidth byte
+		codeWidth, err = r.ReadByte()
+		if err != nil {
+			return buffer, unexpectedEOF(err)
+		}
+		buffer = append(buffer, codeWidth)
+		if codeWidth == 0 {
+			break
+		}
+		if codes == legacyMaxCodes {
+			return buffer, fmt.Errorf("%w: more than %d codes", ErrCorruptTable, legacyMaxCodes)
+		}
+		// character and code
+		buffer, err = appendFull(r, buffer, 1+(uint64(codeWidth)+7)/8)
+		if err != nil {
+			return buffer, err
+		}
+	}
+	return appendData(r, buffer, len(buffer)-start, check)
+}
+
+// append data width and encoded data of a block to buffer
+//
+// check is called before reading encoded data, see readBlock
+func appendData(r *bufio.Reader, buffer []byte, tableSize int, check func(tableSize int, dataSize uint64) error) (ret []byte, err error) {
+	// read data width
+	buffer, err = appendFull(r, buffer, 8)
+	if err != nil {
+		return buffer, err
+	}
+	var dataWidth uint64 = binary.BigEndian.Uint64(buffer[len(buffer)-8:])
+
+	// read data
+	var dataSize uint64 = dataWidth / 8
+	if dataWidth%8 != 0 {
+		dataSize++
+	}
+	if check != nil {
+		err = check(tableSize, dataSize)
+		if err != nil {
+			return buffer, err
+		}
+	}
+	return appendFull(r, buffer, dataSize)
+}
+
+// append n bytes read from r to buffer
+//
+// buffer grows with the data actually read, not with n
+func appendFull(r io.Reader, buffer []byte, n uint64) (ret []byte, err error) {
+	var writer *bytes.Buffer = bytes.NewBuffer(buffer)
+	_, err = io.CopyN(writer, r, int64(n))
+	return writer.Bytes(), unexpectedEOF(err)
+}
+
+// EOF inside a block means truncated data
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
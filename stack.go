@@ -1,4 +1,4 @@
-package main
+package huffman
 
 type Stack[T any] struct {
 	data []T
@@ -1,4 +1,12 @@
-package main
+package huffman
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
 
 // generic binary tree
 type Tree[T any] struct {
@@ -29,3 +37,71 @@ func (tree *Tree[T]) Height() (height int) {
 		return rightHeight + 1
 	}
 }
+
+// write the tree as a Graphviz DOT digraph
+//
+// label returns the label of each node, may contain newlines,
+// leaves are drawn as boxes, edges to left and right children are labelled 0 and 1
+func (tree *Tree[T]) WriteDot(w io.Writer, label func(node *Tree[T]) string) (err error) {
+	var buffer *bufio.Writer = bufio.NewWriter(w)
+	buffer.WriteString("digraph tree {\n")
+	if tree != nil {
+		var id int = 0
+		tree.writeDotNode(buffer, label, &id)
+	}
+	buffer.WriteString("}\n")
+	return buffer.Flush()
+}
+
+// write node and its children, id is the next unused node id
+func (tree *Tree[T]) writeDotNode(w *bufio.Writer, label func(node *Tree[T]) string, id *int) (nodeID int) {
+	nodeID = *id
+	*id++
+	var shape string = "ellipse"
+	if tree.Left == nil && tree.Right == nil {
+		shape = "box"
+	}
+	fmt.Fprintf(w, "\tn%d [label=\"%s\", shape=%s];\n", nodeID, dotEscape(label(tree)), shape)
+	for bit, child := range []*Tree[T]{tree.Left, tree.Right} {
+		if child == nil {
+			continue
+		}
+		var childID int = child.writeDotNode(w, label, id)
+		fmt.Fprintf(w, "\tn%d -> n%d [label=\"%d\"];\n", nodeID, childID, bit)
+	}
+	return nodeID
+}
+
+// escape str for a quoted DOT string, newlines become line breaks
+func dotEscape(str string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(str)
+}
+
+// node of a tree in JSON, see WriteJSON
+type jsonTreeNode struct {
+	Value any           `json:"value"`
+	Left  *jsonTreeNode `json:"left,omitempty"`
+	Right *jsonTreeNode `json:"right,omitempty"`
+}
+
+// write the tree as nested JSON objects {"value": ..., "left": ..., "right": ...}
+//
+// value returns the JSON value of each node, missing children are omitted,
+// an empty tree is written as null
+func (tree *Tree[T]) WriteJSON(w io.Writer, value func(node *Tree[T]) any) (err error) {
+	var encoder *json.Encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(tree.jsonNode(value))
+}
+
+// convert node and its children, nil if tree is nil
+func (tree *Tree[T]) jsonNode(value func(node *Tree[T]) any) (ret *jsonTreeNode) {
+	if tree == nil {
+		return nil
+	}
+	return &jsonTreeNode{
+		Value: value(tree),
+		Left:  tree.Left.jsonNode(value),
+		Right: tree.Right.jsonNode(value),
+	}
+}
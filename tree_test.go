@@ -0,0 +1,83 @@
+package huffman
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// node and edge statements written by WriteDot
+var dotNodeLine = regexp.MustCompile(`^\tn(\d+) \[label="((?:[^"\\]|\\.)*)", shape=(box|ellipse)\];$`)
+var dotEdgeLine = regexp.MustCompile(`^\tn\d+ -> n\d+ \[label="[01]"\];$`)
+
+// reverse dotEscape, ok is false on escapes it does not write
+func dotUnescape(str string) (ret string, ok bool) {
+	var builder strings.Builder
+	for i := 0; i < len(str); i++ {
+		if str[i] != '\\' {
+			builder.WriteByte(str[i])
+			continue
+		}
+		i++
+		switch str[i] {
+		case '\\', '"':
+			builder.WriteByte(str[i])
+		case 'n':
+			builder.WriteByte('\n')
+		default:
+			return "", false
+		}
+	}
+	return builder.String(), true
+}
+
+// labels of any bytes are quoted so DOT reads them back unchanged
+func TestWriteDotEscape(t *testing.T) {
+	var labels []string
+	for char := 0; char < 256; char++ {
+		labels = append(labels, string([]byte{byte(char)}), fmt.Sprintf("%c\\%c\"\n", char, char))
+	}
+	labels = append(labels, "", `\n`, `\"`, `"\`, "a\nb\n")
+
+	// a left-leaning chain with one leaf per label
+	var tree *Tree[string] = NewTree(labels[0])
+	for _, label := range labels[1:] {
+		var parent *Tree[string] = NewTree("")
+		parent.Left, parent.Right = tree, NewTree(label)
+		tree = parent
+	}
+
+	var buffer bytes.Buffer
+	if err := tree.WriteDot(&buffer, func(node *Tree[string]) string { return node.Value }); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string = strings.Split(buffer.String(), "\n")
+	if lines[0] != "digraph tree {" || lines[len(lines)-2] != "}" || lines[len(lines)-1] != "" {
+		t.Fatalf("unexpected digraph start or end:\n%s", buffer.String())
+	}
+	var leaves map[string]int = make(map[string]int)
+	for _, line := range lines[1 : len(lines)-2] {
+		if dotEdgeLine.MatchString(line) {
+			continue
+		}
+		var match []string = dotNodeLine.FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("invalid line %q", line)
+		}
+		label, ok := dotUnescape(match[2])
+		if !ok {
+			t.Fatalf("invalid escape in %q", line)
+		}
+		if match[3] == "box" {
+			leaves[label]++
+		}
+	}
+	for _, label := range labels {
+		if leaves[label] == 0 {
+			t.Errorf("label %q not found", label)
+		}
+		leaves[label]--
+	}
+}
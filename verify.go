@@ -0,0 +1,155 @@
+package huffman
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type BatchVerifyResult struct {
+	InputPath    string
+	TotalCount   int
+	SuccessCount int
+	Time         time.Duration
+	Errors       []BatchError
+}
+
+// decode input file and discard decoded data
+//
+// huffman tables, encoded data, sizes and the checksum if stored are checked,
+// nothing is written
+func Verify(inputPath string) (decodeSize DecodeSize, decodeTime time.Duration, err error) {
+	return VerifyWithOptions(inputPath, nil)
+}
+
+// same as Verify, only limits and Progress of options are used, options may be nil for no limit
+func VerifyWithOptions(inputPath string, options *DecodeOptions) (decodeSize DecodeSize, decodeTime time.Duration, err error) {
+	return VerifyContext(context.Background(), inputPath, options)
+}
+
+// same as VerifyWithOptions, stop when ctx is done
+func VerifyContext(ctx context.Context, inputPath string, options *DecodeOptions) (decodeSize DecodeSize, decodeTime time.Duration, err error) {
+	// record start time
+	var startTime time.Time = time.Now()
+	if options == nil {
+		options = &DecodeOptions{}
+	}
+	var event ProgressEvent = ProgressEvent{Path: inputPath, Total: -1}
+	defer func() {
+		event.Type = ProgressFileFinished
+		event.Err = err
+		event.Time = time.Since(startTime)
+		event.DecodeSize = decodeSize
+		options.Progress.report(event)
+	}()
+	err = ctx.Err()
+	if err != nil {
+		return decodeSize, decodeTime, err
+	}
+
+	// open input file
+	var inputFile *os.File
+	inputFile, err = os.Open(inputPath)
+	if err != nil {
+		return decodeSize, decodeTime, fmt.Errorf("open input file %s failed:\n%w", inputPath, err)
+	}
+	defer inputFile.Close()
+	var inputInfo os.FileInfo
+	inputInfo, err = inputFile.Stat()
+	if err != nil {
+		return decodeSize, decodeTime, fmt.Errorf("stat input file %s failed:\n%w", inputPath, err)
+	}
+	event.Type = ProgressFileStarted
+	event.Total = inputInfo.Size()
+	options.Progress.report(event)
+
+	// decode input to nowhere
+	var input *progressReader = newProgressReader(ctx, inputFile, options.Progress, event)
+	var reader *Reader = NewReaderWithOptions(input, options)
+	var decoded int64
+	decoded, err = io.Copy(io.Discard, reader)
+	event.Bytes = input.event.Bytes
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return decodeSize, decodeTime, fmt.Errorf("verify file %s canceled:\n%w", inputPath, ctxErr)
+	}
+	if err != nil {
+		return decodeSize, decodeTime, fmt.Errorf("verify file %s failed:\n%w", inputPath, err)
+	}
+
+	// record size and time
+	decodeSize = DecodeSize{
+		Original: int(inputInfo.Size()),
+		Decoded:  int(decoded),
+		Checksum: reader.Checksum(),
+	}
+	decodeTime = time.Since(startTime)
+	return decodeSize, decodeTime, nil
+}
+
+// verify all files in input directory, see Verify
+func BatchVerify(inputPath string) (result BatchVerifyResult, err error) {
+	return BatchVerifyWithOptions(inputPath, nil)
+}
+
+// same as BatchVerify, options are used for each file
+func BatchVerifyWithOptions(inputPath string, options *DecodeOptions) (result BatchVerifyResult, err error) {
+	return BatchVerifyContext(context.Background(), inputPath, options)
+}
+
+// same as BatchVerifyWithOptions, stop when ctx is done
+//
+// files not finished when ctx is done are reported as errors, and ctx.Err() is returned with the result
+func BatchVerifyContext(ctx context.Context, inputPath string, options *DecodeOptions) (result BatchVerifyResult, err error) {
+	// record start time
+	var startTime time.Time = time.Now()
+	var fileOptions DecodeOptions
+	if options != nil {
+		fileOptions = *options
+	}
+	fileOptions.Progress = fileOptions.Progress.serialized()
+
+	// collect input files
+	inputPath = filepath.Clean(inputPath)
+	var inputFiles []string
+	var errors []BatchError
+	inputFiles, errors, err = GetFilesInDir(inputPath)
+	if err != nil {
+		return result, fmt.Errorf("get input files failed: %w", err)
+	}
+	if errors == nil {
+		errors = make([]BatchError, 0)
+	}
+	reportBatchErrors(fileOptions.Progress, errors)
+
+	// process files with a bounded number of goroutines
+	var verifyErrors []error = make([]error, len(inputFiles))
+	runBatch(ctx, len(inputFiles), fileOptions.Jobs, fileOptions.BatchMemory, func(idx int) int64 {
+		return decodeMemory(inputFiles[idx])
+	}, func(idx int) {
+		_, _, verifyErrors[idx] = VerifyContext(ctx, inputFiles[idx], &fileOptions)
+	})
+
+	// collect results in input order
+	var success int = 0
+	for idx, verifyErr := range verifyErrors {
+		if verifyErr != nil {
+			errors = append(errors, BatchError{Path: inputFiles[idx], Err: verifyErr})
+		} else {
+			success++
+		}
+	}
+	sortBatchErrors(errors)
+
+	// fill result
+	result = BatchVerifyResult{
+		InputPath:    inputPath,
+		TotalCount:   len(inputFiles),
+		SuccessCount: success,
+		Time:         time.Since(startTime),
+		Errors:       errors,
+	}
+	return result, ctx.Err()
+}
@@ -0,0 +1,47 @@
+package huffman
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// verifying fails on data that is not huffman encoded, and on nothing else
+func TestVerifyNotHuffman(t *testing.T) {
+	var dir string = t.TempDir()
+	var input string = filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, benchmarkText(1000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Encode(input, filepath.Join(dir, "encoded.bin")); err != nil {
+		t.Fatal(err)
+	}
+	var garbage []byte = make([]byte, 500)
+	rand.New(rand.NewSource(1)).Read(garbage)
+	garbage[0] = 3
+	writeTestFiles(t, dir, map[string]string{
+		"zeros99": string(make([]byte, 99)),
+		"garbage": string(garbage),
+	})
+
+	if _, _, err := Verify(filepath.Join(dir, "encoded.bin")); err != nil {
+		t.Fatalf("verify encoded: %v", err)
+	}
+	for _, name := range []string{"zeros99", "garbage"} {
+		_, _, err := Verify(filepath.Join(dir, name))
+		var formatErr *FormatError
+		if !errors.Is(err, ErrNotHuffman) || !errors.As(err, &formatErr) {
+			t.Errorf("verify %s: got %v, want *FormatError with ErrNotHuffman", name, err)
+		}
+	}
+
+	if err := os.Remove(input); err != nil {
+		t.Fatal(err)
+	}
+	result, err := BatchVerify(dir)
+	if err != nil || result.TotalCount != 3 || result.SuccessCount != 1 || len(result.Errors) != 2 {
+		t.Errorf("batch verify: %d of %d files succeeded, %d errors, %v", result.SuccessCount, result.TotalCount, len(result.Errors), err)
+	}
+}
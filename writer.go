@@ -0,0 +1,277 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// default number of input bytes encoded in one block
+const DefaultBlockSize = 1 << 20
+
+// max number of input bytes encoded in one block
+const MaxBlockSize = 1<<31 - 1
+
+// options of encoding
+//
+// Force, Verify, RemoveInput, NoMetadata and AccessTime are used by Encode and BatchEncode only,
+// Jobs and BatchMemory by BatchEncode only, Progress by Encode and BatchEncode
+type EncodeOptions struct {
+	BlockSize    int               // in bytes, DefaultBlockSize if 0, no more than MaxBlockSize
+	MaxCodeWidth int               // in bits, DefaultMaxCodeWidth if 0
+	Checksum     ChecksumAlgorithm // DefaultChecksum if ChecksumDefault
+	Force        bool              // overwrite existing output files
+	Verify       bool              // decode output and compare it with input before saving it
+	RemoveInput  bool              // remove input file after encoded file is verified
+	NoMetadata   bool              // do not store name, mode and times of input file, output depends on content only
+	AccessTime   bool              // also store access time of input file, output then changes whenever input is read
+	Jobs         int               // files encoded at the same time in batch mode, GOMAXPROCS if 0
+	BatchMemory  int64             // in bytes, estimated memory of files encoded at the same time in batch mode, 0 for no limit
+	Progress     ProgressFunc      // called with progress of each file, may be nil
+}
+
+// Writer compresses data written to it and writes it to the underlying writer
+//
+// input is buffered and encoded block by block, each block with its own
+// huffman table, so memory use is bounded by the block size
+//
+// Header may be set before the first Write, an unknown Size is filled in
+// when the whole input fits in the first block
+//
+// format:
+//
+//	n bytes  : header and metadata (see Header)
+//	n group of:
+//	    4 bytes  : original size of block (in bytes)
+//	    huffman table (see writeHuffmanTable)
+//	    encoded data  (see writeString)
+//	4 bytes  : 0 (end of stream)
+//	n bytes  : checksum of original data, size depends on algorithm
+type Writer struct {
+	Header Header
+
+	w             io.Writer
+	options       EncodeOptions
+	buffer        []byte
+	headerWritten bool
+	hash          hash.Hash
+	written       int64 // original bytes written
+	size          EncodeSize
+	codeGenTime   time.Duration
+	err           error
+	closed        bool
+}
+
+// create a new writer writing compressed data to w
+//
+// options may be nil to use default options
+func NewWriter(w io.Writer, options *EncodeOptions) (ret *Writer) {
+	ret = new(Writer)
+	if options != nil {
+		ret.options = *options
+	}
+	if ret.options.BlockSize <= 0 {
+		ret.options.BlockSize = DefaultBlockSize
+	}
+	if ret.options.BlockSize > MaxBlockSize {
+		ret.options.BlockSize = MaxBlockSize
+	}
+	if ret.options.MaxCodeWidth == 0 {
+		ret.options.MaxCodeWidth = DefaultMaxCodeWidth
+	}
+	if ret.options.Checksum == ChecksumDefault {
+		ret.options.Checksum = DefaultChecksum
+	}
+	ret.Reset(w)
+	return ret
+}
+
+// discard the writer state and write to w, keep options
+func (writer *Writer) Reset(w io.Writer) {
+	writer.Header = Header{Size: -1, Checksum: writer.options.Checksum}
+	writer.w = w
+	writer.buffer = writer.buffer[:0]
+	writer.headerWritten = false
+	writer.hash = nil
+	writer.written = 0
+	writer.size = EncodeSize{}
+	writer.codeGenTime = 0
+	writer.err = nil
+	writer.closed = false
+}
+
+// write data to the writer, encode a block each time the buffer is full
+func (writer *Writer) Write(p []byte) (n int, err error) {
+	if writer.err != nil {
+		return 0, writer.err
+	}
+	if writer.closed {
+		return 0, fmt.Errorf("write to closed huffman writer")
+	}
+
+	for len(p) > 0 {
+		// fill buffer
+		var free int = writer.options.BlockSize - len(writer.buffer)
+		if free > len(p) {
+			free = len(p)
+		}
+		writer.buffer = append(writer.buffer, p[:free]...)
+		p = p[free:]
+		n += free
+
+		// encode full block
+		if len(writer.buffer) == writer.options.BlockSize {
+			err = writer.writeBlock()
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// encode buffered data as a block and write it to the underlying writer
+func (writer *Writer) Flush() error {
+	if writer.err != nil {
+		return writer.err
+	}
+	if len(writer.buffer) == 0 {
+		return nil
+	}
+	return writer.writeBlock()
+}
+
+// flush buffered data and write end of stream, does not close the underlying writer
+func (writer *Writer) Close() (err error) {
+	if writer.closed {
+		return writer.err
+	}
+	writer.closed = true
+	if writer.err != nil {
+		return writer.err
+	}
+
+	// whole input in buffer, original size is known
+	if !writer.headerWritten && writer.Header.Size < 0 {
+		writer.Header.Size = int64(len(writer.buffer))
+	}
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+	err = writer.writeHeader()
+	if err != nil {
+		return err
+	}
+	if writer.Header.Size >= 0 && writer.Header.Size != writer.written {
+		writer.err = fmt.Errorf("written %d bytes, header declares %d bytes", writer.written, writer.Header.Size)
+		return writer.err
+	}
+
+	// end of stream
+	var end [blockHeaderSize]byte
+	_, err = writer.w.Write(end[:])
+	if err != nil {
+		writer.err = fmt.Errorf("write end of stream failed: %w", err)
+		return writer.err
+	}
+	writer.size.HuffmanTable += blockHeaderSize
+
+	// checksum
+	if writer.hash != nil {
+		writer.size.Checksum.Value = writer.hash.Sum(nil)
+		_, err = writer.w.Write(writer.size.Checksum.Value)
+		if err != nil {
+			writer.err = fmt.Errorf("write checksum failed: %w", err)
+			return writer.err
+		}
+		writer.size.HuffmanTable += len(writer.size.Checksum.Value)
+	}
+	return nil
+}
+
+// checksum of all written data, valid after Close
+func (writer *Writer) Checksum() Checksum {
+	return writer.size.Checksum
+}
+
+// write header if not written yet
+func (writer *Writer) writeHeader() (err error) {
+	if writer.headerWritten {
+		return nil
+	}
+	if writer.Header.Checksum == ChecksumDefault {
+		writer.Header.Checksum = DefaultChecksum
+	}
+	writer.hash = newChecksumHash(writer.Header.Checksum)
+	if writer.hash == nil && writer.Header.Checksum != ChecksumNone {
+		writer.err = fmt.Errorf("unknown checksum algorithm %v", writer.Header.Checksum)
+		return writer.err
+	}
+	writer.size.Checksum = Checksum{Algorithm: writer.Header.Checksum}
+
+	var size int
+	size, err = writeHeader(writer.w, writer.Header)
+	if err != nil {
+		writer.err = err
+		return err
+	}
+	writer.headerWritten = true
+	writer.size.HuffmanTable += size
+	return nil
+}
+
+// encode buffer as a block
+func (writer *Writer) writeBlock() (err error) {
+	// get huffman codes
+	var startTime time.Time = time.Now()
+	var codes HuffmanCodes
+	codes, err = GetLimitedHuffmanCodes(string(writer.buffer), writer.options.MaxCodeWidth)
+	if err != nil {
+		writer.err = fmt.Errorf("generate huffman codes failed: %w", err)
+		return writer.err
+	}
+	writer.codeGenTime += time.Since(startTime)
+
+	// write block header
+	err = writer.writeHeader()
+	if err != nil {
+		return err
+	}
+	var blockHeader [blockHeaderSize]byte
+	binary.BigEndian.PutUint32(blockHeader[:], uint32(len(writer.buffer)))
+	_, err = writer.w.Write(blockHeader[:])
+	if err != nil {
+		writer.err = fmt.Errorf("write block header failed: %w", err)
+		return writer.err
+	}
+
+	// write huffman table
+	var huffmanTableSize int
+	huffmanTableSize, err = writeHuffmanTable(writer.w, codes)
+	if err != nil {
+		writer.err = err
+		return err
+	}
+
+	// write string
+	var dataSize int
+	dataSize, err = writeString(writer.w, writer.buffer, codes)
+	if err != nil {
+		writer.err = err
+		return err
+	}
+
+	// record size and checksum
+	if writer.hash != nil {
+		writer.hash.Write(writer.buffer)
+	}
+	writer.written += int64(len(writer.buffer))
+	writer.size.Original += len(writer.buffer)
+	writer.size.HuffmanTable += blockHeaderSize + huffmanTableSize
+	writer.size.EncodedData += dataSize
+	writer.buffer = writer.buffer[:0]
+	return nil
+}
@@ -0,0 +1,130 @@
+package huffman
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// primes of xxhash64
+const (
+	xxPrime1 uint64 = 0x9E3779B185EBCA87
+	xxPrime2 uint64 = 0xC2B2AE3D27D4EB4F
+	xxPrime3 uint64 = 0x165667B19E3779F9
+	xxPrime4 uint64 = 0x85EBCA77C2B2AE63
+	xxPrime5 uint64 = 0x27D4EB2F165667C5
+)
+
+// xxhash64 with seed 0, implements hash.Hash64
+//
+// sum is written in big endian, the canonical form of xxhash
+type xxhash64 struct {
+	v      [4]uint64
+	buffer [32]byte
+	length int // bytes in buffer
+	total  uint64
+}
+
+// create a new xxhash64
+func newXXHash64() (ret *xxhash64) {
+	ret = new(xxhash64)
+	ret.Reset()
+	return ret
+}
+
+func (hash *xxhash64) Reset() {
+	var prime1, prime2 uint64 = xxPrime1, xxPrime2
+	hash.v = [4]uint64{prime1 + prime2, prime2, 0, -prime1}
+	hash.length = 0
+	hash.total = 0
+}
+
+func (hash *xxhash64) Size() int {
+	return 8
+}
+
+func (hash *xxhash64) BlockSize() int {
+	return 32
+}
+
+func (hash *xxhash64) Write(p []byte) (n int, err error) {
+	n = len(p)
+	hash.total += uint64(n)
+
+	// fill buffer first
+	if hash.length > 0 {
+		var copied int = copy(hash.buffer[hash.length:], p)
+		hash.length += copied
+		p = p[copied:]
+		if hash.length < len(hash.buffer) {
+			return n, nil
+		}
+		hash.stripe(hash.buffer[:])
+		hash.length = 0
+	}
+
+	// whole stripes
+	for len(p) >= 32 {
+		hash.stripe(p[:32])
+		p = p[32:]
+	}
+	hash.length = copy(hash.buffer[:], p)
+	return n, nil
+}
+
+// process a 32 bytes stripe
+func (hash *xxhash64) stripe(p []byte) {
+	for i := range hash.v {
+		hash.v[i] = xxRound(hash.v[i], binary.LittleEndian.Uint64(p[i*8:]))
+	}
+}
+
+func (hash *xxhash64) Sum64() uint64 {
+	var ret uint64
+	if hash.total >= 32 {
+		ret = bits.RotateLeft64(hash.v[0], 1) + bits.RotateLeft64(hash.v[1], 7) +
+			bits.RotateLeft64(hash.v[2], 12) + bits.RotateLeft64(hash.v[3], 18)
+		for _, v := range hash.v {
+			ret ^= xxRound(0, v)
+			ret = ret*xxPrime1 + xxPrime4
+		}
+	} else {
+		ret = xxPrime5
+	}
+	ret += hash.total
+
+	// remaining bytes in buffer
+	var p []byte = hash.buffer[:hash.length]
+	for len(p) >= 8 {
+		ret ^= xxRound(0, binary.LittleEndian.Uint64(p))
+		ret = bits.RotateLeft64(ret, 27)*xxPrime1 + xxPrime4
+		p = p[8:]
+	}
+	if len(p) >= 4 {
+		ret ^= uint64(binary.LittleEndian.Uint32(p)) * xxPrime1
+		ret = bits.RotateLeft64(ret, 23)*xxPrime2 + xxPrime3
+		p = p[4:]
+	}
+	for _, b := range p {
+		ret ^= uint64(b) * xxPrime5
+		ret = bits.RotateLeft64(ret, 11) * xxPrime1
+	}
+
+	// avalanche
+	ret ^= ret >> 33
+	ret *= xxPrime2
+	ret ^= ret >> 29
+	ret *= xxPrime3
+	ret ^= ret >> 32
+	return ret
+}
+
+func (hash *xxhash64) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, hash.Sum64())
+}
+
+// round of xxhash64
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}